@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// AutoTile16 is the autotile mode, selecting how neighbours are turned into a
+// mask. It looks at the 4 edge neighbours: N=1, E=2, S=4, W=8
+const AutoTile16 = "16"
+
+// autoTileRules is the on-disk format of an autotile rule file
+type autoTileRules struct {
+	Sheet      string `json:"sheet"`      // Sprite sheet path within the filesystem
+	TileWidth  int    `json:"tileWidth"`  // Size of each sprite in the sheet
+	TileHeight int    `json:"tileHeight"` // Size of each sprite in the sheet
+	Mode       string `json:"mode"`       // AutoTile16
+	Default    [2]int `json:"default"`    // Sprite (row, col) for masks without a rule
+	Rules      []struct {
+		Masks []int  `json:"masks"` // Neighbour masks using this sprite
+		Tile  [2]int `json:"tile"`  // Sprite (row, col) in the sheet
+	} `json:"rules"`
+}
+
+// AutoTiler picks wall sprites based on which neighbouring tiles are also solid
+type AutoTiler struct {
+	sheet    *SpriteSheet
+	tiles    map[int]*ebiten.Image
+	fallback *ebiten.Image
+}
+
+// NewAutoTilerFromFS loads an autotile rule file, and the sprite sheet it refers to, from a filesystem
+func NewAutoTilerFromFS(filesystem fs.FS, rulePath string) *AutoTiler {
+	data, err := fs.ReadFile(filesystem, rulePath)
+	if err != nil {
+		log.Printf("Failed to read autotile rules %s: %v", rulePath, err)
+		return nil
+	}
+
+	var rules autoTileRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		log.Printf("Failed to parse autotile rules %s: %v", rulePath, err)
+		return nil
+	}
+
+	const maxMask = 16
+	if rules.Mode != AutoTile16 {
+		log.Printf("Unknown autotile mode %q in %s", rules.Mode, rulePath)
+		return nil
+	}
+
+	sheet := NewSpriteSheetFromFS(filesystem, rules.Sheet, rules.TileWidth, rules.TileHeight)
+	if sheet == nil {
+		return nil
+	}
+
+	a := &AutoTiler{
+		sheet:    sheet,
+		tiles:    map[int]*ebiten.Image{},
+		fallback: sheet.GetTileImageByCoord(rules.Default[0], rules.Default[1]),
+	}
+	for _, rule := range rules.Rules {
+		for _, mask := range rule.Masks {
+			if mask < 0 || mask >= maxMask {
+				log.Printf("Autotile mask %d out of range in %s", mask, rulePath)
+				continue
+			}
+			a.tiles[mask] = sheet.GetTileImageByCoord(rule.Tile[0], rule.Tile[1])
+		}
+	}
+
+	return a
+}
+
+// Mask calculates the neighbour mask for the tile at the given grid coordinates
+func (a *AutoTiler) Mask(m *GameMap, x, y int) int {
+	n, e, s, w := m.IsSolid(x, y-1), m.IsSolid(x+1, y), m.IsSolid(x, y+1), m.IsSolid(x-1, y)
+	return maskBits(n, 1) | maskBits(e, 2) | maskBits(s, 4) | maskBits(w, 8)
+}
+
+// maskBits returns bit if set is true, otherwise 0
+func maskBits(set bool, bit int) int {
+	if set {
+		return bit
+	}
+	return 0
+}
+
+// TileImage returns the sprite for the tile at the given grid coordinates
+func (a *AutoTiler) TileImage(m *GameMap, x, y int) *ebiten.Image {
+	if img, ok := a.tiles[a.Mask(m, x, y)]; ok {
+		return img
+	}
+	return a.fallback
+}
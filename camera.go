@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	minCameraZoom = 0.5
+	maxCameraZoom = 3.0
+
+	// Smallest zoom picked automatically, below which tiles get too small to play on a phone
+	minPlayableZoom = 0.75
+)
+
+// Camera controls which part of the world is visible on screen
+type Camera struct {
+	X, Y           float64 // World position shown at the centre of the screen
+	Zoom           float64 // Screen pixels per world pixel
+	ViewWidth      int     // Screen width in pixels
+	ViewHeight     int     // Screen height in pixels
+	Smoothing      float64 // Fraction of the remaining distance covered each update (0-1, where 1 = snap)
+	DeadZoneWidth  float64 // Width of the central area the target can move in without the camera following
+	DeadZoneHeight float64 // Height of the central area the target can move in without the camera following
+}
+
+// NewCamera creates a camera for a screen of the given size
+func NewCamera(viewWidth, viewHeight int) *Camera {
+	return &Camera{
+		Zoom:           1.0,
+		ViewWidth:      viewWidth,
+		ViewHeight:     viewHeight,
+		Smoothing:      0.1,
+		DeadZoneWidth:  96,
+		DeadZoneHeight: 64,
+	}
+}
+
+// Follow moves the camera towards the target, then clamps it to the given world bounds
+func (c *Camera) Follow(targetX, targetY float64, bounds image.Rectangle) {
+	// Only chase the target once it leaves the dead zone
+	desiredX := deadZoneTarget(c.X, targetX, c.DeadZoneWidth/2)
+	desiredY := deadZoneTarget(c.Y, targetY, c.DeadZoneHeight/2)
+
+	c.X += (desiredX - c.X) * c.Smoothing
+	c.Y += (desiredY - c.Y) * c.Smoothing
+	c.Clamp(bounds)
+}
+
+// deadZoneTarget returns where the camera needs to be on one axis so that the
+// target is no further than halfZone from its centre
+func deadZoneTarget(current, target, halfZone float64) float64 {
+	if target > current+halfZone {
+		return target - halfZone
+	}
+	if target < current-halfZone {
+		return target + halfZone
+	}
+	return current
+}
+
+// CenterOn immediately moves the camera to the given world position
+func (c *Camera) CenterOn(x, y float64, bounds image.Rectangle) {
+	c.X = x
+	c.Y = y
+	c.Clamp(bounds)
+}
+
+// Clamp keeps the view inside the world bounds. If the world is smaller than the
+// view on an axis, it is centred on that axis instead
+func (c *Camera) Clamp(bounds image.Rectangle) {
+	c.X = clampAxis(c.X, float64(bounds.Min.X), float64(bounds.Max.X), float64(c.ViewWidth)/c.Zoom/2)
+	c.Y = clampAxis(c.Y, float64(bounds.Min.Y), float64(bounds.Max.Y), float64(c.ViewHeight)/c.Zoom/2)
+}
+
+// clampAxis clamps a camera centre so that centre +/- halfView stays within min..max
+func clampAxis(centre, min, max, halfView float64) float64 {
+	if max-min <= halfView*2 {
+		return (min + max) / 2
+	}
+	return math.Max(min+halfView, math.Min(max-halfView, centre))
+}
+
+// SetZoom changes the zoom level, keeping it within a sensible range
+func (c *Camera) SetZoom(zoom float64) {
+	c.Zoom = math.Max(minCameraZoom, math.Min(maxCameraZoom, zoom))
+}
+
+// SetViewSize changes the size of the screen the camera draws to
+func (c *Camera) SetViewSize(viewWidth, viewHeight int) {
+	c.ViewWidth = viewWidth
+	c.ViewHeight = viewHeight
+}
+
+// FitZoom picks a zoom level that shows as much of the world as possible,
+// without shrinking tiles below a playable size or enlarging them past 1:1
+func (c *Camera) FitZoom(bounds image.Rectangle) {
+	if bounds.Empty() {
+		return
+	}
+	zoom := math.Min(float64(c.ViewWidth)/float64(bounds.Dx()), float64(c.ViewHeight)/float64(bounds.Dy()))
+	c.SetZoom(math.Max(minPlayableZoom, math.Min(1.0, zoom)))
+}
+
+// GeoM returns the transform from world coordinates to screen coordinates
+func (c *Camera) GeoM() ebiten.GeoM {
+	var geoM ebiten.GeoM
+	geoM.Translate(-c.X, -c.Y)
+	geoM.Scale(c.Zoom, c.Zoom)
+	geoM.Translate(float64(c.ViewWidth)/2, float64(c.ViewHeight)/2)
+	return geoM
+}
+
+// WorldToScreen converts world coordinates to screen coordinates
+func (c *Camera) WorldToScreen(x, y float64) (float64, float64) {
+	return (x-c.X)*c.Zoom + float64(c.ViewWidth)/2, (y-c.Y)*c.Zoom + float64(c.ViewHeight)/2
+}
+
+// VisibleRect returns the area of the world currently visible on screen
+func (c *Camera) VisibleRect() image.Rectangle {
+	halfWidth := float64(c.ViewWidth) / c.Zoom / 2
+	halfHeight := float64(c.ViewHeight) / c.Zoom / 2
+	return image.Rect(
+		int(math.Floor(c.X-halfWidth)),
+		int(math.Floor(c.Y-halfHeight)),
+		int(math.Ceil(c.X+halfWidth)),
+		int(math.Ceil(c.Y+halfHeight)),
+	)
+}
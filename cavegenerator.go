@@ -0,0 +1,250 @@
+package main
+
+import (
+	"image"
+	"math/rand"
+)
+
+// CaveGenerator creates organic, open cave levels using cellular automata.
+// Random noise is smoothed into caverns, tiny pockets are filled in, and the
+// remaining caverns are joined up with tunnels so the whole cave is reachable
+type CaveGenerator struct {
+	width  int
+	height int
+	cave   [][]byte
+	rng    *rand.Rand
+
+	FillProbability float64 // Chance of each tile starting as wall
+	SmoothingSteps  int     // Number of smoothing passes
+	WallThreshold   int     // A tile with at least this many wall neighbours becomes wall
+	FloorThreshold  int     // A tile with at most this many wall neighbours becomes floor
+	MinRegionSize   int     // Caverns smaller than this are filled in rather than connected
+	TunnelWidth     int     // Width of the tunnels joining caverns, in tiles
+}
+
+// NewCaveGenerator creates a new cave generator with the specified dimensions.
+// The same seed always produces the same cave
+func NewCaveGenerator(width, height int, seed int64) *CaveGenerator {
+	return &CaveGenerator{
+		width:           width,
+		height:          height,
+		rng:             rand.New(rand.NewSource(seed)),
+		FillProbability: 0.45,
+		SmoothingSteps:  5,
+		WallThreshold:   5,
+		FloorThreshold:  3,
+		MinRegionSize:   12,
+		TunnelWidth:     2,
+	}
+}
+
+// Generate creates the cave, returning one string per row in the ASCII format used by NewGameMap
+func (cg *CaveGenerator) Generate() []string {
+	cg.randomFill()
+	for i := 0; i < cg.SmoothingSteps; i++ {
+		cg.smooth()
+	}
+	cg.connectRegions()
+
+	result := make([]string, cg.height)
+	for y, row := range cg.cave {
+		result[y] = string(row)
+	}
+	return result
+}
+
+// randomFill scatters walls at random, with a solid border
+func (cg *CaveGenerator) randomFill() {
+	cg.cave = make([][]byte, cg.height)
+	for y := range cg.cave {
+		cg.cave[y] = make([]byte, cg.width)
+		for x := range cg.cave[y] {
+			if cg.isBorder(x, y) || cg.rng.Float64() < cg.FillProbability {
+				cg.cave[y][x] = '#'
+			} else {
+				cg.cave[y][x] = '.'
+			}
+		}
+	}
+}
+
+// isBorder checks if a tile is on the edge of the cave
+func (cg *CaveGenerator) isBorder(x, y int) bool {
+	return x == 0 || y == 0 || x == cg.width-1 || y == cg.height-1
+}
+
+// wallsAround counts the walls in the 8 tiles around the given one. Outside the cave counts as wall
+func (cg *CaveGenerator) wallsAround(x, y int) int {
+	count := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			nx, ny := x+dx, y+dy
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			if nx < 0 || ny < 0 || nx >= cg.width || ny >= cg.height || cg.cave[ny][nx] == '#' {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// smooth applies one pass of the cellular automaton, clumping walls together
+func (cg *CaveGenerator) smooth() {
+	next := make([][]byte, cg.height)
+	for y := range cg.cave {
+		next[y] = make([]byte, cg.width)
+		for x := range cg.cave[y] {
+			walls := cg.wallsAround(x, y)
+			switch {
+			case cg.isBorder(x, y) || walls >= cg.WallThreshold:
+				next[y][x] = '#'
+			case walls <= cg.FloorThreshold:
+				next[y][x] = '.'
+			default:
+				next[y][x] = cg.cave[y][x]
+			}
+		}
+	}
+	cg.cave = next
+}
+
+// regions returns every connected area of floor, largest first
+func (cg *CaveGenerator) regions() [][]image.Point {
+	seen := make([][]bool, cg.height)
+	for y := range seen {
+		seen[y] = make([]bool, cg.width)
+	}
+
+	var regions [][]image.Point
+	for y := 0; y < cg.height; y++ {
+		for x := 0; x < cg.width; x++ {
+			if seen[y][x] || cg.cave[y][x] == '#' {
+				continue
+			}
+			// Flood fill from here
+			seen[y][x] = true
+			region := []image.Point{image.Pt(x, y)}
+			for i := 0; i < len(region); i++ {
+				for _, step := range cellSteps {
+					n := region[i].Add(step)
+					if !seen[n.Y][n.X] && cg.cave[n.Y][n.X] != '#' {
+						seen[n.Y][n.X] = true
+						region = append(region, n)
+					}
+				}
+			}
+			regions = append(regions, region)
+		}
+	}
+
+	// Stable insertion sort, so ties keep their top-to-bottom order
+	for i := 1; i < len(regions); i++ {
+		for j := i; j > 0 && len(regions[j]) > len(regions[j-1]); j-- {
+			regions[j], regions[j-1] = regions[j-1], regions[j]
+		}
+	}
+	return regions
+}
+
+// connectRegions fills in tiny caverns, then tunnels between the rest until
+// they're all joined to the largest one. The largest is kept however small it
+// is, and if smoothing left no floor at all a tunnel is dug corner to corner,
+// so there's always somewhere to roll
+func (cg *CaveGenerator) connectRegions() {
+	var regions [][]image.Point
+	for i, region := range cg.regions() {
+		if i == 0 || len(region) >= cg.MinRegionSize {
+			regions = append(regions, region)
+			continue
+		}
+		for _, p := range region {
+			cg.cave[p.Y][p.X] = '#'
+		}
+	}
+	if len(regions) == 0 {
+		cg.tunnel(image.Pt(1, 1), image.Pt(cg.width-2, cg.height-2))
+		return
+	}
+
+	// Grow the connected area one region at a time, always joining the closest
+	connected := cg.edgeTiles(regions[0])
+	remaining := regions[1:]
+	for len(remaining) > 0 {
+		bestRegion := 0
+		var bestFrom, bestTo image.Point
+		bestDistance := -1
+		for i, region := range remaining {
+			for _, to := range cg.edgeTiles(region) {
+				for _, from := range connected {
+					d := from.Sub(to)
+					distance := d.X*d.X + d.Y*d.Y
+					if bestDistance < 0 || distance < bestDistance {
+						bestRegion, bestFrom, bestTo, bestDistance = i, from, to, distance
+					}
+				}
+			}
+		}
+		cg.tunnel(bestFrom, bestTo)
+		connected = append(connected, cg.edgeTiles(remaining[bestRegion])...)
+		remaining = append(remaining[:bestRegion], remaining[bestRegion+1:]...)
+	}
+}
+
+// edgeTiles returns the floor tiles of a region that touch a wall. Tunnels
+// always start and end at one of these
+func (cg *CaveGenerator) edgeTiles(region []image.Point) []image.Point {
+	var edges []image.Point
+	for _, p := range region {
+		for _, step := range cellSteps {
+			n := p.Add(step)
+			if cg.cave[n.Y][n.X] == '#' {
+				edges = append(edges, p)
+				break
+			}
+		}
+	}
+	return edges
+}
+
+// tunnel digs a straight passage between two tiles, TunnelWidth tiles wide
+func (cg *CaveGenerator) tunnel(from, to image.Point) {
+	d := to.Sub(from)
+	steps := max(abs(d.X), abs(d.Y))
+	for i := 0; i <= steps; i++ {
+		// Step along the line, rounding to the nearest tile
+		x := from.X + (d.X*i*2+sign(d.X)*steps)/(steps*2)
+		y := from.Y + (d.Y*i*2+sign(d.Y)*steps)/(steps*2)
+		if steps == 0 {
+			x, y = from.X, from.Y
+		}
+		for dy := 0; dy < cg.TunnelWidth; dy++ {
+			for dx := 0; dx < cg.TunnelWidth; dx++ {
+				tx, ty := x+dx-cg.TunnelWidth/2, y+dy-cg.TunnelWidth/2
+				if tx > 0 && ty > 0 && tx < cg.width-1 && ty < cg.height-1 {
+					cg.cave[ty][tx] = '.'
+				}
+			}
+		}
+	}
+}
+
+// abs returns the absolute value of an integer
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// sign returns -1, 0 or 1 depending on the sign of an integer
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
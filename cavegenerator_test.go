@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSmallCavesHaveFloor(t *testing.T) {
+	for _, size := range []int{minMazeSize, 7, 9, 11} {
+		t.Run(fmt.Sprintf("%dx%d", size, size), func(t *testing.T) {
+			for seed := int64(0); seed < 50; seed++ {
+				maze := CreateMazeWithSpecialTiles(MazeOptions{Width: size, Height: size, Seed: seed, Algorithm: "cave"})
+				if len(maze.Solution) < 2 {
+					t.Errorf("seed %d: no route between a separate start and goal:\n%s", seed, strings.Join(maze.Lines, "\n"))
+				}
+			}
+		})
+	}
+}
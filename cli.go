@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cliSeedPlaceholder is replaced by each maze's seed in output file names
+const cliSeedPlaceholder = "{seed}"
+
+// runGenerate is the `TiltMan generate` subcommand, which writes mazes out
+// without opening a window so they can be produced in bulk and reviewed.
+// It returns the process exit code
+func runGenerate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: TiltMan generate [flags]")
+		fmt.Fprintln(stderr, "Writes generated mazes as ascii (for reading, with the solution marked '+'),")
+		fmt.Fprintln(stderr, "level (the format the game loads) or png")
+		flags.PrintDefaults()
+	}
+
+	opts := MazeOptions{MarbleRadius: 15}
+	flags.StringVar(&opts.Algorithm, "algorithm", RecursiveBacktracker{}.Name(), "generator: "+strings.Join(GeneratorNames(), ", "))
+	flags.IntVar(&opts.Width, "width", 41, "width in tiles")
+	flags.IntVar(&opts.Height, "height", 25, "height in tiles")
+	flags.Int64Var(&opts.Seed, "seed", newSeed(), "seed of the first maze; later ones use the following seeds")
+	flags.Float64Var(&opts.SpecialTileDensity, "special", 0.15, "fraction of floor tiles turned into speed tiles")
+	flags.Float64Var(&opts.BraidFraction, "braid", 0, "fraction of dead ends removed")
+	flags.Float64Var(&opts.LoopDensity, "loops", 0, "chance of knocking out each remaining wall")
+	flags.Float64Var(&opts.HoleDensity, "holes", 0, "fraction of floor tiles away from the solution turned into holes")
+	flags.Float64Var(&opts.HoleClearance, "hole-clearance", 8, "smallest gap in pixels between the marble and any hole along the solution")
+	flags.IntVar(&opts.CorridorWidth, "corridor", 1, "corridor width in tiles")
+	flags.IntVar(&opts.WallThickness, "wall", 1, "wall thickness in tiles")
+	flags.IntVar(&opts.Prefabs, "vaults", 0, "number of prefab vaults to stamp in")
+	flags.IntVar(&opts.Floors, "floors", 1, "number of floors")
+	count := flags.Int("count", 1, "number of mazes to generate")
+	format := flags.String("format", "ascii", "output format: ascii, level or png")
+	out := flags.String("o", "-", "output file, or - for standard output. With -count, "+cliSeedPlaceholder+" is replaced by each maze's seed")
+	scale := flags.Int("scale", 8, "pixels per tile in png output")
+	stream := flags.Bool("stream", false, "write plain eller mazes in level format a row at a time, for sizes too big to hold in memory. Only the size, seed and count apply")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	switch {
+	case flags.NArg() > 0:
+		fmt.Fprintf(stderr, "Unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return 2
+	case !slices.Contains(GeneratorNames(), opts.Algorithm):
+		fmt.Fprintf(stderr, "Unknown algorithm %q, choose from: %s\n", opts.Algorithm, strings.Join(GeneratorNames(), ", "))
+		return 2
+	case opts.Width < minMazeSize || opts.Height < minMazeSize:
+		fmt.Fprintf(stderr, "Mazes must be at least %dx%d\n", minMazeSize, minMazeSize)
+		return 2
+	case *stream && flagSet(flags, "format") && *format != "level":
+		fmt.Fprintln(stderr, "Streamed mazes can only be written in level format")
+		return 2
+	case *format != "ascii" && *format != "level" && *format != "png":
+		fmt.Fprintf(stderr, "Unknown format %q, choose from: ascii, level, png\n", *format)
+		return 2
+	case *format == "png" && *out == "-":
+		fmt.Fprintln(stderr, "png output needs a file name, given with -o")
+		return 2
+	case *count > 1 && *out != "-" && !strings.Contains(*out, cliSeedPlaceholder):
+		fmt.Fprintf(stderr, "Generating more than one maze needs %s in the output file name\n", cliSeedPlaceholder)
+		return 2
+	}
+
+	// Ensure odd dimensions for proper maze structure, as the game does
+	if opts.Width%2 == 0 {
+		opts.Width--
+	}
+	if opts.Height%2 == 0 {
+		opts.Height--
+	}
+
+	for i := 0; i < *count; i++ {
+		mazeOpts := opts
+		mazeOpts.Seed = opts.Seed + int64(i)
+		if *stream {
+			if code := streamMazeTo(*out, i, mazeOpts, stdout, stderr); code != 0 {
+				return code
+			}
+			continue
+		}
+		floors := CreateMultiFloorMaze(mazeOpts)
+
+		var data []byte
+		switch *format {
+		case "ascii":
+			data = []byte(asciiReport(floors))
+		case "level":
+			lines := make([][]string, len(floors))
+			for i, floor := range floors {
+				lines[i] = floor.Lines
+			}
+			data = []byte(strings.TrimSuffix(JoinFloors(lines), "\n") + "\n")
+		case "png":
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, renderLevelImage(floors, *scale)); err != nil {
+				fmt.Fprintf(stderr, "Failed to encode maze %d: %v\n", mazeOpts.Seed, err)
+				return 1
+			}
+			data = buf.Bytes()
+		}
+
+		if *out == "-" {
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			stdout.Write(data)
+			continue
+		}
+		name := strings.ReplaceAll(*out, cliSeedPlaceholder, strconv.FormatInt(mazeOpts.Seed, 10))
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			fmt.Fprintf(stderr, "Failed to write %s: %v\n", name, err)
+			return 1
+		}
+		fmt.Fprintf(stderr, "Wrote %s\n", name)
+	}
+	return 0
+}
+
+// flagSet checks if a flag was given on the command line, rather than left at its default
+func flagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// streamMazeTo writes the i-th of a batch of streamed mazes to the named output
+// file, or standard output, returning the process exit code
+func streamMazeTo(out string, i int, opts MazeOptions, stdout, stderr io.Writer) int {
+	if out == "-" {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		if err := StreamMaze(stdout, opts.Width, opts.Height, opts.Seed); err != nil {
+			fmt.Fprintf(stderr, "Failed to write maze %d: %v\n", opts.Seed, err)
+			return 1
+		}
+		return 0
+	}
+
+	name := strings.ReplaceAll(out, cliSeedPlaceholder, strconv.FormatInt(opts.Seed, 10))
+	file, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create %s: %v\n", name, err)
+		return 1
+	}
+	err = StreamMaze(file, opts.Width, opts.Height, opts.Seed)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write %s: %v\n", name, err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "Wrote %s\n", name)
+	return 0
+}
+
+// asciiReport describes each floor of a level for reading: what it was
+// generated with, how long the solution is, and the maze with the solution
+// marked along its plain floor tiles
+func asciiReport(floors []*MazeResult) string {
+	var report strings.Builder
+	for i, floor := range floors {
+		o := floor.Options
+		fmt.Fprintf(&report, "Seed: %d  Algorithm: %s  Size: %dx%d  Floor: %d/%d  Solution: %d steps\n",
+			o.Seed, o.Algorithm, len(floor.Lines[0]), len(floor.Lines), i+1, len(floors), max(0, len(floor.Solution)-1))
+
+		lines := make([][]byte, len(floor.Lines))
+		for y, line := range floor.Lines {
+			lines[y] = []byte(line)
+		}
+		for _, p := range floor.Solution {
+			if lines[p.Y][p.X] == '.' {
+				lines[p.Y][p.X] = '+'
+			}
+		}
+		for _, line := range lines {
+			report.Write(line)
+			report.WriteByte('\n')
+		}
+	}
+	return report.String()
+}
+
+// renderLevelImage draws every floor of a level side by side, using the same
+// colours as the in-game floor overviews, scale pixels to a tile
+func renderLevelImage(floors []*MazeResult, scale int) *image.RGBA {
+	scale = max(1, scale)
+	width, height := 0, 0
+	for i, floor := range floors {
+		if i > 0 {
+			width++ // Gap between floors
+		}
+		width += len(floor.Lines[0])
+		height = max(height, len(floor.Lines))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	left := 0
+	for _, floor := range floors {
+		for y, line := range floor.Lines {
+			for x := range line {
+				c := thumbnailColors[TileFloor]
+				if def, ok := tileChars[rune(line[x])]; ok && def.layer == LayerGround {
+					c = thumbnailColors[def.typ]
+				}
+				fillRect(img, image.Rect((left+x)*scale, y*scale, (left+x+1)*scale, (y+1)*scale), c)
+			}
+		}
+		left += len(floor.Lines[0]) + 1
+	}
+	return img
+}
+
+// fillRect fills a rectangle of an image with a single colour
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
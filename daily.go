@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// DailyChallenge is the maze of the day. Its seed comes from the UTC date and
+// everything else about it is fixed, so every player gets the same maze on the
+// same day without needing a server. The best time and number of attempts are
+// kept locally, per day
+type DailyChallenge struct {
+	Date     string        // UTC date of the challenge, as YYYY-MM-DD
+	Seed     int64         // Seed derived from the date
+	Record   dailyRecord   // How the player has done today
+	ticks    int           // Ticks since the current attempt started
+	started  bool          // Whether the current attempt has started, which it does at the first tilt
+	finished bool          // Whether the goal has been reached, so the result screen is showing
+	lastTime time.Duration // Time of the attempt just finished
+}
+
+// dailyRecord is what's stored about a day's challenge
+type dailyRecord struct {
+	Attempts int
+	BestTime time.Duration // Zero until the challenge has been finished
+}
+
+// NewDailyChallenge returns the challenge for the UTC day of the given time,
+// along with the player's record for it so far
+func NewDailyChallenge(now time.Time) *DailyChallenge {
+	year, month, day := now.UTC().Date()
+	d := &DailyChallenge{
+		Date: now.UTC().Format("2006-01-02"),
+		Seed: int64(year*10000 + int(month)*100 + day),
+	}
+	loadRecord(d.recordKey(), &d.Record)
+	return d
+}
+
+// Options returns how the challenge's maze is generated. Only the seed changes from day to day
+func (d *DailyChallenge) Options(marbleRadius float64) MazeOptions {
+	return MazeOptions{
+		Width:              41,
+		Height:             25,
+		Seed:               d.Seed,
+		Algorithm:          RecursiveBacktracker{}.Name(),
+		SpecialTileDensity: 0.15,
+		BraidFraction:      0.2,
+		HoleDensity:        0.05,
+		HoleClearance:      8,
+		MarbleRadius:       marbleRadius,
+		Prefabs:            1,
+	}
+}
+
+// recordKey returns the key the day's record is stored under
+func (d *DailyChallenge) recordKey() string {
+	return "daily-" + d.Date
+}
+
+// StartAttempt starts the clock on a new attempt at the challenge, counting it
+func (d *DailyChallenge) StartAttempt() {
+	d.ticks = 0
+	d.finished = false
+	d.started = true
+	d.Record.Attempts++
+	saveRecord(d.recordKey(), d.Record)
+}
+
+// Restart gets ready for another attempt, which starts at the next tilt
+func (d *DailyChallenge) Restart() {
+	d.ticks = 0
+	d.finished = false
+	d.started = false
+}
+
+// Tick advances the clock on the current attempt, once it's started
+func (d *DailyChallenge) Tick() {
+	if d.started && !d.finished {
+		d.ticks++
+	}
+}
+
+// Elapsed returns how long the current attempt has taken, in game time
+func (d *DailyChallenge) Elapsed() time.Duration {
+	return time.Duration(d.ticks) * time.Second / time.Duration(ebiten.TPS())
+}
+
+// Finish stops the clock on the current attempt, keeping its time if it's the best yet
+func (d *DailyChallenge) Finish() {
+	d.finished = true
+	d.lastTime = d.Elapsed()
+	if d.Record.BestTime == 0 || d.lastTime < d.Record.BestTime {
+		d.Record.BestTime = d.lastTime
+	}
+	saveRecord(d.recordKey(), d.Record)
+	log.Printf("Daily challenge %s complete in %s!", d.Date, formatTime(d.lastTime))
+}
+
+// formatTime formats a time as minutes, seconds and hundredths
+func formatTime(t time.Duration) string {
+	return fmt.Sprintf("%d:%05.2f", int(t.Minutes()), (t % time.Minute).Seconds())
+}
+
+// startDaily starts today's challenge. The generation options are left alone,
+// so leaving the challenge goes back to the same kind of maze as before
+func (g *Game) startDaily() {
+	g.endless = nil
+	g.daily = NewDailyChallenge(time.Now())
+	g.loadFloors([]*MazeResult{CreateMazeWithSpecialTiles(g.daily.Options(g.marble.Radius))})
+	g.camera.FitZoom(g.gameMap.Bounds())
+}
+
+// updateDaily starts an attempt at the first tilt after the challenge is
+// opened or tried again, and keeps the clock running. Falling in a hole or
+// resetting carries on with the same attempt, so each is only counted once
+func (g *Game) updateDaily(input InputFrame) {
+	if !g.daily.started && input.Tilting {
+		g.daily.StartAttempt()
+	}
+	g.daily.Tick()
+}
+
+// updateDailyResult handles input on the result screen. Enter or resetting
+// tries the challenge again, and Escape, asking for a new maze or switching
+// the daily challenge off leaves it
+func (g *Game) updateDailyResult(input InputFrame) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter) || input.Has(ActionReset) {
+		g.daily.Restart()
+		g.resetMarble()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || input.Has(ActionDaily|ActionNewMaze) {
+		g.generateNewMaze()
+	}
+}
+
+// drawDailyResult draws the result screen for a finished daily challenge in the middle of the screen
+func (g *Game) drawDailyResult(screen *ebiten.Image) {
+	const lineHeight = 16
+	lines := []string{
+		"Daily challenge complete!",
+		"",
+		fmt.Sprintf("Date:     %s", g.daily.Date),
+		fmt.Sprintf("Seed:     %d", g.daily.Seed),
+		fmt.Sprintf("Time:     %s", formatTime(g.daily.lastTime)),
+		fmt.Sprintf("Best:     %s", formatTime(g.daily.Record.BestTime)),
+		fmt.Sprintf("Attempts: %d", g.daily.Record.Attempts),
+		"",
+		"R/Enter/X: try again  C/Esc/Y: leave",
+	}
+	width := 280
+	height := (len(lines) + 1) * lineHeight
+	x := (g.screenWidth - width) / 2
+	y := (g.screenHeight - height) / 2
+
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{0, 0, 0, 200}, false)
+	for i, line := range lines {
+		ebitenutil.DebugPrintAt(screen, line, x+8, y+4+i*lineHeight)
+	}
+}
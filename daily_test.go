@@ -0,0 +1,43 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestDailyCountsOneAttemptPerStart(t *testing.T) {
+	g := &Game{
+		screenWidth:  800,
+		screenHeight: 600,
+		marble:       NewMarble(0, 0, 15, color.White),
+		camera:       NewCamera(800, 600),
+	}
+	before := NewDailyChallenge(time.Now()).Record.Attempts // Whatever was stored from earlier runs today
+	g.startDaily()
+	if got := g.daily.Record.Attempts - before; got != 0 {
+		t.Fatalf("opening the daily challenge counted %d attempts, want none until the first tilt", got)
+	}
+
+	tilt := InputFrame{TiltX: 1, Tilting: true}
+	g.updateDaily(InputFrame{})
+	g.updateDaily(tilt)
+	g.updateDaily(tilt)
+	g.resetMarble() // Falling in a hole
+	g.updateDaily(tilt)
+	if got := g.daily.Record.Attempts - before; got != 1 {
+		t.Errorf("got %d attempts after opening the challenge and falling in a hole, want 1", got)
+	}
+	if g.daily.ticks != 3 {
+		t.Errorf("clock ran for %d ticks, want 3 from the first tilt", g.daily.ticks)
+	}
+
+	// Trying again once it's finished is another attempt
+	g.daily.Finish()
+	g.daily.Restart()
+	g.resetMarble()
+	g.updateDaily(tilt)
+	if got := g.daily.Record.Attempts - before; got != 2 {
+		t.Errorf("got %d attempts after trying again, want 2", got)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// DifficultyReport breaks down how hard a map is to complete
+type DifficultyReport struct {
+	SolutionLength  int     // Tiles on the shortest safe route from start to goal
+	Turns           int     // Changes of direction along the route
+	DecisionPoints  int     // Tiles on the route with more than one way onwards
+	DeadEndDepth    int     // Total length of the side branches off the route that lead nowhere
+	MaxDeadEndDepth int     // Length of the longest of those side branches
+	NarrowTiles     int     // Route tiles where the corridor is tight for the marble
+	MinClearance    float64 // Smallest gap in pixels between the marble and the corridor walls along the route
+	HoleAdjacent    int     // Route tiles with a hole next to them
+	Solvable        bool    // Whether the goal can be reached at all
+	Score           float64 // Overall difficulty from 0 (trivial) towards 100 (brutal)
+}
+
+// difficultyWeights control how much each feature contributes to the score
+var difficultyWeights = struct {
+	SolutionLength, Turns, DecisionPoints, DeadEndDepth, NarrowTiles, HoleAdjacent float64
+	Scale                                                                          float64 // Raw total that scores about 63
+}{
+	SolutionLength: 0.1,
+	Turns:          0.5,
+	DecisionPoints: 1.5,
+	DeadEndDepth:   0.2,
+	NarrowTiles:    0.2,
+	HoleAdjacent:   2.0,
+	Scale:          120,
+}
+
+// DifficultyBand is a range of acceptable difficulty scores. The zero band accepts anything
+type DifficultyBand struct {
+	Min, Max float64
+}
+
+// Contains checks if a score is within the band
+func (b DifficultyBand) Contains(score float64) bool {
+	return b.Max == 0 || (score >= b.Min && score <= b.Max)
+}
+
+// distanceFrom returns how far a score is outside the band, or 0 if it's inside
+func (b DifficultyBand) distanceFrom(score float64) float64 {
+	if b.Contains(score) {
+		return 0
+	}
+	return math.Max(b.Min-score, score-b.Max)
+}
+
+// Named difficulty bands, in menu order
+var difficultyBands = []struct {
+	Name string
+	Band DifficultyBand
+}{
+	{"any", DifficultyBand{}},
+	{"easy", DifficultyBand{0, 35}},
+	{"medium", DifficultyBand{35, 60}},
+	{"hard", DifficultyBand{60, 80}},
+	{"expert", DifficultyBand{80, 100}},
+}
+
+// campaignDifficulty is the name of the band that ramps up with each level completed
+const campaignDifficulty = "campaign"
+
+// CampaignDifficulty returns the band for a level of a campaign, ramping up
+// smoothly from easy to expert
+func CampaignDifficulty(level int) DifficultyBand {
+	low := math.Min(85, 10+float64(level)*5)
+	return DifficultyBand{Min: low, Max: low + 15}
+}
+
+// DifficultyBandByName returns the named difficulty band. The campaign band
+// depends on the level, and unknown names accept any difficulty
+func DifficultyBandByName(name string, level int) DifficultyBand {
+	if name == campaignDifficulty {
+		return CampaignDifficulty(level)
+	}
+	for _, named := range difficultyBands {
+		if named.Name == name {
+			return named.Band
+		}
+	}
+	return DifficultyBand{}
+}
+
+// AnalyzeDifficulty works out how hard it is for a marble of the given radius
+// to get from the start of the map to the goal. Holes are treated as impassable.
+// If there's no goal tile, the farthest reachable tile is used
+func AnalyzeDifficulty(m *GameMap, marbleRadius float64) DifficultyReport {
+	report := DifficultyReport{MinClearance: math.Inf(1)}
+	open := func(p image.Point) bool {
+		return !m.IsSolid(p.X, p.Y) && !m.IsHole(p.X, p.Y)
+	}
+
+	// The route is found on the map's ASCII rows, so it crosses bridges the way the marble does
+	lines := m.routeLines()
+	dist := DistanceField(lines, m.Start)
+	goal, found := m.findTile(TileGoal)
+	if !found {
+		goal = farthestTile(dist)
+	}
+	if dist[goal.Y][goal.X] < 0 {
+		return DifficultyReport{} // Not solvable
+	}
+	report.Solvable = true
+	route := solutionPath(dist, goal, lines)
+	report.SolutionLength = len(route)
+
+	// Corridor width across the direction of travel
+	widths := make([]int, len(route))
+	narrowest := math.MaxInt
+	for i, p := range route {
+		along := image.Pt(1, 0)
+		if i+1 < len(route) {
+			along = route[i+1].Sub(p)
+		} else if i > 0 {
+			along = p.Sub(route[i-1])
+		}
+		widths[i] = m.corridorWidth(p, image.Pt(along.Y, along.X))
+		narrowest = min(narrowest, widths[i])
+	}
+
+	// In wide corridors the route takes up the whole corridor, so side branches
+	// start beyond the tiles alongside it. Each of those belongs to the nearest route tile
+	band := m.routeBand(route, narrowest-1, open)
+	sections := make([][]image.Point, len(route))
+	for y, row := range band {
+		for x, i := range row {
+			if i >= 0 {
+				sections[i] = append(sections[i], image.Pt(x, y))
+			}
+		}
+	}
+
+	// Side branches already explored, so each is only measured once
+	type sideBranch struct {
+		depth   int
+		deadEnd bool
+	}
+	var branches []sideBranch
+	explored := map[image.Point]int{}
+
+	for i, p := range route {
+		// Turns are where the direction into a tile differs from the direction out
+		if i > 0 && i+1 < len(route) && route[i].Sub(route[i-1]) != route[i+1].Sub(route[i]) {
+			report.Turns++
+		}
+
+		// Decision points have ways on other than the route, each of which is a
+		// potential dead end. Short loops are just the other side of an open area
+		choices := 0
+		for _, t := range sections[i] {
+			for _, step := range cellSteps {
+				n := t.Add(step)
+				if !open(n) || band[n.Y][n.X] >= 0 {
+					continue
+				}
+				b, seen := explored[n]
+				if !seen {
+					depth, deadEnd, tiles := m.branchDepth(t, n, band, narrowest, open)
+					b = len(branches)
+					branches = append(branches, sideBranch{depth, deadEnd})
+					for tile := range tiles {
+						explored[tile] = b
+					}
+					if deadEnd {
+						report.DeadEndDepth += depth
+						report.MaxDeadEndDepth = max(report.MaxDeadEndDepth, depth)
+					}
+				}
+				if branches[b].deadEnd || branches[b].depth >= minLoopLength {
+					choices++
+				}
+			}
+		}
+		if choices > 0 && i+1 < len(route) {
+			report.DecisionPoints++
+		}
+
+		clearance := float64(widths[i]*m.TileSize)/2 - marbleRadius
+		report.MinClearance = math.Min(report.MinClearance, clearance)
+		if clearance < float64(m.TileSize)/4 {
+			report.NarrowTiles++
+		}
+
+		if m.nextToHole(p) {
+			report.HoleAdjacent++
+		}
+	}
+
+	w := difficultyWeights
+	raw := w.SolutionLength*float64(report.SolutionLength) +
+		w.Turns*float64(report.Turns) +
+		w.DecisionPoints*float64(report.DecisionPoints) +
+		w.DeadEndDepth*float64(report.DeadEndDepth) +
+		w.NarrowTiles*float64(report.NarrowTiles) +
+		w.HoleAdjacent*float64(report.HoleAdjacent)
+	report.Score = 100 * (1 - math.Exp(-raw/w.Scale))
+	return report
+}
+
+// distanceField returns the number of steps from the given tile to every other
+// tile, only moving through tiles for which open returns true
+func (m *GameMap) distanceField(from image.Point, open func(image.Point) bool) [][]int {
+	dist := make([][]int, m.Height)
+	for y := range dist {
+		dist[y] = make([]int, m.Width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	if !open(from) {
+		return dist
+	}
+	dist[from.Y][from.X] = 0
+	queue := []image.Point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, step := range cellSteps {
+			n := p.Add(step)
+			if open(n) && dist[n.Y][n.X] < 0 {
+				dist[n.Y][n.X] = dist[p.Y][p.X] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return dist
+}
+
+// routeLines returns the map's ground as ASCII rows, with just enough detail
+// for DistanceField and solutionPath: walls, holes, bridges and open floor
+func (m *GameMap) routeLines() []string {
+	lines := make([]string, m.Height)
+	row := make([]byte, m.Width)
+	for y := range lines {
+		for x := range row {
+			switch typ := m.GetType(x, y); {
+			case m.IsSolid(x, y):
+				row[x] = '#'
+			case typ == TileHole:
+				row[x] = 'O'
+			case typ == TileBridgeNS:
+				row[x] = '|'
+			case typ == TileBridgeEW:
+				row[x] = '-'
+			default:
+				row[x] = '.'
+			}
+		}
+		lines[y] = string(row)
+	}
+	return lines
+}
+
+// findTile returns the first ground tile of the given type, top to bottom
+func (m *GameMap) findTile(typ TileType) (image.Point, bool) {
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.GetType(x, y) == typ {
+				return image.Pt(x, y), true
+			}
+		}
+	}
+	return image.Point{}, false
+}
+
+// corridorWidth returns the number of open tiles in a line across the corridor through p
+func (m *GameMap) corridorWidth(p, across image.Point) int {
+	// On a bridge the corridor is as wide as the deck, not the corridor it crosses
+	_, onBridge := bridgeDeck(m.GetType(p.X, p.Y))
+	width := 1
+	for _, dir := range []image.Point{across, across.Mul(-1)} {
+		for n := p.Add(dir); !m.IsSolid(n.X, n.Y); n = n.Add(dir) {
+			if _, isBridge := bridgeDeck(m.GetType(n.X, n.Y)); onBridge && !isBridge {
+				break
+			}
+			width++
+		}
+	}
+	return width
+}
+
+// routeBand marks the tiles within radius steps of a route with the index of
+// the nearest route tile. Everything else is -1
+func (m *GameMap) routeBand(route []image.Point, radius int, open func(image.Point) bool) [][]int {
+	band := make([][]int, m.Height)
+	dist := make([][]int, m.Height)
+	for y := range band {
+		band[y] = make([]int, m.Width)
+		dist[y] = make([]int, m.Width)
+		for x := range band[y] {
+			band[y][x] = -1
+		}
+	}
+	queue := make([]image.Point, 0, len(route))
+	for i, p := range route {
+		band[p.Y][p.X] = i
+		queue = append(queue, p)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if dist[p.Y][p.X] >= radius {
+			continue
+		}
+		for _, step := range cellSteps {
+			n := p.Add(step)
+			if open(n) && band[n.Y][n.X] < 0 {
+				band[n.Y][n.X] = band[p.Y][p.X]
+				dist[n.Y][n.X] = dist[p.Y][p.X] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return band
+}
+
+// minLoopLength is the shortest distance a side branch can go before rejoining
+// the route and still be a separate way round, rather than part of an open area
+const minLoopLength = 3
+
+// branchDepth measures how far a side branch leading off the route's band at
+// origin goes, returning the tiles it explored. It isn't a dead end if it loops
+// back round to the band, in which case the depth is how far it went before
+// rejoining. Touching the band within reach route tiles of origin doesn't count,
+// as wide branches meet the band across their whole mouth
+func (m *GameMap) branchDepth(origin, from image.Point, band [][]int, reach int, open func(image.Point) bool) (int, bool, map[image.Point]int) {
+	dist := map[image.Point]int{from: 1}
+	queue := []image.Point{from}
+	depth := 1
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		depth = max(depth, dist[p])
+		for _, step := range cellSteps {
+			n := p.Add(step)
+			if !open(n) {
+				continue
+			}
+			if band[n.Y][n.X] >= 0 {
+				if abs(band[n.Y][n.X]-band[origin.Y][origin.X]) > reach {
+					return dist[p], false, dist
+				}
+				continue
+			}
+			if _, seen := dist[n]; !seen {
+				dist[n] = dist[p] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return depth, true, dist
+}
+
+// nextToHole checks if any of the 8 tiles around the given one is a hole
+func (m *GameMap) nextToHole(p image.Point) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && m.IsHole(p.X+dx, p.Y+dy) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateMazeWithDifficulty generates mazes until one lands in the difficulty band
+// for a marble of the given radius. Each attempt uses the next seed, and the
+// braiding is nudged up or down depending on whether the last attempt was too
+// hard or too easy. If nothing lands in the band, the closest attempt is returned
+func CreateMazeWithDifficulty(opts MazeOptions, band DifficultyBand, marbleRadius float64, attempts int) (*MazeResult, DifficultyReport) {
+	var best *MazeResult
+	var bestReport DifficultyReport
+	bestDistance := math.Inf(1)
+
+	for attempt := 0; attempt < max(1, attempts); attempt++ {
+		maze := CreateMazeWithSpecialTiles(opts)
+		report := AnalyzeDifficulty(NewGameMap(strings.Join(maze.Lines, "\n"), tileSize, 0, 0), marbleRadius)
+		if distance := band.distanceFrom(report.Score); distance < bestDistance {
+			best, bestReport, bestDistance = maze, report, distance
+		}
+		if bestDistance == 0 {
+			break
+		}
+
+		opts.Seed++
+		if report.Score > band.Max {
+			opts.BraidFraction = math.Min(1, opts.BraidFraction+0.1) // Loops make it easier
+		} else {
+			opts.BraidFraction = math.Max(0, opts.BraidFraction-0.1)
+		}
+	}
+	return best, bestReport
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"image"
+	"math/rand"
+)
+
+// DungeonGenerator creates dungeon-style levels of rooms joined by corridors.
+// The level is split up with binary space partitioning, a room is placed in
+// each partition, and sibling partitions are joined with corridors. Some rooms
+// are given themed contents
+type DungeonGenerator struct {
+	width  int
+	height int
+	grid   [][]byte
+	rng    *rand.Rand
+	rooms  []image.Rectangle
+
+	MinLeafSize   int     // Partitions are never split smaller than this, in tiles
+	MinRoomSize   int     // Smallest room width or height, in tiles
+	CorridorWidth int     // Width of the corridors between rooms, in tiles
+	ThemeChance   float64 // Chance of each room being given a theme
+}
+
+// Room themes
+const (
+	themeArena   = iota // A checkerboard of fast tiles and floor, ringed with mildly fast ones
+	themeHoles          // A grid of holes, with safe lanes between
+	themePellets        // Pellets to collect, scattered across the floor
+	themeCount
+)
+
+// NewDungeonGenerator creates a new dungeon generator with the specified dimensions.
+// The same seed always produces the same dungeon
+func NewDungeonGenerator(width, height int, seed int64) *DungeonGenerator {
+	return &DungeonGenerator{
+		width:         width,
+		height:        height,
+		rng:           rand.New(rand.NewSource(seed)),
+		MinLeafSize:   8,
+		MinRoomSize:   4,
+		CorridorWidth: 2,
+		ThemeChance:   0.5,
+	}
+}
+
+// Generate creates the dungeon, returning one string per row in the ASCII format used by NewGameMap
+func (dg *DungeonGenerator) Generate() []string {
+	dg.grid = make([][]byte, dg.height)
+	for y := range dg.grid {
+		dg.grid[y] = make([]byte, dg.width)
+		for x := range dg.grid[y] {
+			dg.grid[y][x] = '#'
+		}
+	}
+	dg.rooms = nil
+
+	if dg.width > 2 && dg.height > 2 {
+		dg.partition(image.Rect(1, 1, dg.width-1, dg.height-1))
+	}
+	for _, room := range dg.rooms {
+		if dg.rng.Float64() < dg.ThemeChance {
+			dg.decorate(room, dg.rng.Intn(themeCount))
+		}
+	}
+
+	result := make([]string, dg.height)
+	for y, row := range dg.grid {
+		result[y] = string(row)
+	}
+	return result
+}
+
+// Rooms returns the rooms of the last generated dungeon
+func (dg *DungeonGenerator) Rooms() []image.Rectangle {
+	return dg.rooms
+}
+
+// partition splits an area in two, recursing until it's too small to split,
+// then places a room in it. The halves are joined by a corridor between a room
+// in each, and one of those rooms is returned
+func (dg *DungeonGenerator) partition(area image.Rectangle) image.Rectangle {
+	canSplitX := area.Dx() >= dg.MinLeafSize*2
+	canSplitY := area.Dy() >= dg.MinLeafSize*2
+	if !canSplitX && !canSplitY {
+		return dg.placeRoom(area)
+	}
+
+	// Prefer cutting across the longer side, so partitions stay roughly square
+	var first, second image.Rectangle
+	if canSplitX && (!canSplitY || area.Dx() > area.Dy() || (area.Dx() == area.Dy() && dg.rng.Intn(2) == 0)) {
+		split := area.Min.X + dg.MinLeafSize + dg.rng.Intn(area.Dx()-dg.MinLeafSize*2+1)
+		first = image.Rect(area.Min.X, area.Min.Y, split, area.Max.Y)
+		second = image.Rect(split, area.Min.Y, area.Max.X, area.Max.Y)
+	} else {
+		split := area.Min.Y + dg.MinLeafSize + dg.rng.Intn(area.Dy()-dg.MinLeafSize*2+1)
+		first = image.Rect(area.Min.X, area.Min.Y, area.Max.X, split)
+		second = image.Rect(area.Min.X, split, area.Max.X, area.Max.Y)
+	}
+
+	a := dg.partition(first)
+	b := dg.partition(second)
+	dg.corridor(dg.randomPoint(a), dg.randomPoint(b))
+	if dg.rng.Intn(2) == 0 {
+		return a
+	}
+	return b
+}
+
+// placeRoom carves a randomly sized room somewhere within an area, leaving a
+// wall between it and the area's edges
+func (dg *DungeonGenerator) placeRoom(area image.Rectangle) image.Rectangle {
+	inner := area.Inset(1)
+	if inner.Empty() {
+		inner = area
+	}
+	w := dg.randomSize(inner.Dx())
+	h := dg.randomSize(inner.Dy())
+	x := inner.Min.X + dg.rng.Intn(inner.Dx()-w+1)
+	y := inner.Min.Y + dg.rng.Intn(inner.Dy()-h+1)
+	room := image.Rect(x, y, x+w, y+h)
+
+	dg.carve(room)
+	dg.rooms = append(dg.rooms, room)
+	return room
+}
+
+// randomSize picks a room length that fits in the space available
+func (dg *DungeonGenerator) randomSize(space int) int {
+	if space <= dg.MinRoomSize {
+		return space
+	}
+	return dg.MinRoomSize + dg.rng.Intn(space-dg.MinRoomSize+1)
+}
+
+// randomPoint picks a random tile within a room
+func (dg *DungeonGenerator) randomPoint(room image.Rectangle) image.Point {
+	return image.Pt(room.Min.X+dg.rng.Intn(room.Dx()), room.Min.Y+dg.rng.Intn(room.Dy()))
+}
+
+// corridor carves an L-shaped corridor, CorridorWidth tiles wide, between two tiles
+func (dg *DungeonGenerator) corridor(from, to image.Point) {
+	corner := image.Pt(to.X, from.Y)
+	if dg.rng.Intn(2) == 0 {
+		corner = image.Pt(from.X, to.Y)
+	}
+	width := max(1, dg.CorridorWidth)
+	for _, leg := range [][2]image.Point{{from, corner}, {corner, to}} {
+		r := image.Rectangle{leg[0], leg[1]}.Canon()
+		dg.carve(image.Rect(r.Min.X, r.Min.Y, r.Max.X+width, r.Max.Y+width))
+	}
+}
+
+// carve turns an area into floor, leaving the outer border intact
+func (dg *DungeonGenerator) carve(area image.Rectangle) {
+	area = area.Intersect(image.Rect(1, 1, dg.width-1, dg.height-1))
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			dg.grid[y][x] = '.'
+		}
+	}
+}
+
+// decorate fills a room with themed contents. The outer ring of the room is
+// left clear, so corridors arriving at any point can still get through
+func (dg *DungeonGenerator) decorate(room image.Rectangle, theme int) {
+	inner := room.Inset(1)
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			if dg.grid[y][x] != '.' {
+				continue
+			}
+			rx, ry := x-inner.Min.X, y-inner.Min.Y
+			switch theme {
+			case themeArena:
+				if rx == 0 || ry == 0 || x == inner.Max.X-1 || y == inner.Max.Y-1 {
+					dg.grid[y][x] = ')'
+				} else if (rx+ry)%2 == 0 {
+					dg.grid[y][x] = '>'
+				}
+			case themeHoles:
+				// Every third tile, so there are always two-tile lanes between holes
+				if rx%3 == 1 && ry%3 == 1 && x < inner.Max.X-1 && y < inner.Max.Y-1 {
+					dg.grid[y][x] = 'O'
+				}
+			case themePellets:
+				if dg.rng.Intn(3) == 0 {
+					dg.grid[y][x] = 'o'
+				}
+			}
+		}
+	}
+}
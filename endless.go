@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	endlessChunkRows    = 16 // Rows of tiles generated at a time in endless mode
+	endlessChunksAhead  = 2  // Chunks kept generated below the marble
+	endlessChunksBehind = 1  // Chunks kept above the marble before they're discarded
+)
+
+// EndlessMap is a maze without an end, generated a chunk of rows at a time as
+// the marble rolls down it. The rows come from a MazeStream, which keeps track
+// of how each row joins onto the next, so the seams between chunks are as
+// connected as the rest of the maze. Chunks far behind the marble are discarded,
+// so memory use doesn't grow however far it goes
+type EndlessMap struct {
+	Map     *GameMap
+	stream  *MazeStream
+	deepest int // Furthest row down the maze the marble has reached
+}
+
+// NewEndlessMap creates an endless maze width tiles wide, with the marble
+// starting at the top. The same seed always gives the same maze
+func NewEndlessMap(width int, seed int64, tileSize int, screenWidth, screenHeight int) *EndlessMap {
+	e := &EndlessMap{stream: NewMazeStream(width, seed)}
+
+	rows := []string{strings.Repeat("#", e.stream.Width())}
+	for len(rows) < (endlessChunksAhead+1)*endlessChunkRows {
+		rows = append(rows, e.nextChunk()...)
+	}
+	start := []byte(rows[1])
+	start[1] = 'S'
+	rows[1] = string(start)
+
+	// Every starting row is there before the map is laid out, so it's centred
+	// the same way it will be when the screen is resized
+	e.Map = NewGameMap(strings.Join(rows, "\n"), tileSize, screenWidth, screenHeight)
+	e.deepest = e.Map.Start.Y
+	return e
+}
+
+// nextChunk generates the next rows of the maze
+func (e *EndlessMap) nextChunk() []string {
+	rows := make([]string, 0, endlessChunkRows)
+	for len(rows) < endlessChunkRows {
+		cellRow, wallRow := e.stream.NextRow(false)
+		rows = append(rows, string(cellRow), string(wallRow))
+	}
+	return rows
+}
+
+// Update generates more of the maze ahead of the marble, discards the chunks
+// far behind it, and keeps track of how far it has got
+func (e *EndlessMap) Update(marble *Marble) {
+	_, row, ok := e.Map.gridCoords(marble.X, marble.Y)
+	if !ok {
+		return
+	}
+	e.deepest = max(e.deepest, row)
+	for e.Map.FirstRow+e.Map.Height < row+endlessChunksAhead*endlessChunkRows {
+		e.Map.AppendRows(e.nextChunk())
+	}
+	for e.Map.FirstRow+endlessChunkRows <= row-endlessChunksBehind*endlessChunkRows {
+		e.Map.DiscardRows(endlessChunkRows)
+	}
+}
+
+// Score returns how far the marble has got down the maze, in tiles. Only the
+// furthest point counts, so rolling back and forth doesn't add to it
+func (e *EndlessMap) Score() int {
+	return e.deepest - e.Map.Start.Y
+}
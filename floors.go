@@ -0,0 +1,151 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// floorHeader is the line that starts each floor of a multi-floor level
+const floorHeader = "[floor]"
+
+// FloorMap is a level made of several floors stacked on top of each other.
+// Each floor is its own GameMap, all the same size, and ramps lead between them
+// at matching positions
+type FloorMap struct {
+	Floors  []*GameMap // Floors from the bottom up
+	Current int        // Index of the floor the marble is on
+
+	thumbnails []*ebiten.Image // Overview of each floor, created when first drawn
+}
+
+// NewFloorMap creates a level from an ASCII string with a "[floor]" header line
+// before each floor, from the bottom up. Each floor is parsed by NewGameMap, so
+// can have its own layer sections. A string without any floor headers is a
+// level with a single floor
+func NewFloorMap(asciiMap string, tileSize int, screenWidth, screenHeight int) *FloorMap {
+	var sections []string
+	var current []string
+	for _, line := range strings.Split(asciiMap, "\n") {
+		if strings.TrimSpace(line) == floorHeader {
+			if len(sections) > 0 || strings.TrimSpace(strings.Join(current, "")) != "" {
+				sections = append(sections, strings.Join(current, "\n"))
+			}
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+	sections = append(sections, strings.Join(current, "\n"))
+
+	f := &FloorMap{}
+	for _, section := range sections {
+		f.Floors = append(f.Floors, NewGameMap(section, tileSize, screenWidth, screenHeight))
+	}
+	return f
+}
+
+// JoinFloors combines the ASCII rows of each floor into a single level for NewFloorMap
+func JoinFloors(floors [][]string) string {
+	if len(floors) == 1 {
+		return strings.Join(floors[0], "\n")
+	}
+	var level strings.Builder
+	for _, lines := range floors {
+		level.WriteString(floorHeader + "\n")
+		level.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	return level.String()
+}
+
+// Floor returns the floor the marble is on
+func (f *FloorMap) Floor() *GameMap {
+	return f.Floors[f.Current]
+}
+
+// Climb moves up (positive) or down (negative) the given number of floors,
+// without leaving the building, and returns the new floor
+func (f *FloorMap) Climb(floors int) *GameMap {
+	f.Current = min(len(f.Floors)-1, max(0, f.Current+floors))
+	return f.Floor()
+}
+
+// SetScreenSize centres every floor on a screen of the given size, returning how
+// far the current floor moved
+func (f *FloorMap) SetScreenSize(screenWidth, screenHeight int) (dx, dy int) {
+	for i, floor := range f.Floors {
+		fdx, fdy := floor.SetScreenSize(screenWidth, screenHeight)
+		if i == f.Current {
+			dx, dy = fdx, fdy
+		}
+	}
+	return dx, dy
+}
+
+// thumbnailColors are the colours of each ground tile type in the floor overviews
+var thumbnailColors = map[TileType]color.RGBA{
+	TileWall:     {90, 90, 100, 255},
+	TileFloor:    {60, 120, 60, 255},
+	TileSlow:     {120, 60, 60, 255},
+	TileFast:     {60, 140, 60, 255},
+	TileSlowMild: {100, 70, 70, 255},
+	TileFastMild: {70, 130, 80, 255},
+	TileGoal:     {220, 180, 40, 255},
+	TileHole:     {10, 10, 10, 255},
+	TileRampUp:   {120, 170, 255, 255},
+	TileRampDown: {60, 90, 200, 255},
+	TileBridgeNS: {140, 100, 60, 255},
+	TileBridgeEW: {140, 100, 60, 255},
+}
+
+// Thumbnail returns a small overview of a floor, with a few pixels per tile
+func (f *FloorMap) Thumbnail(floor, scale int) *ebiten.Image {
+	if f.thumbnails == nil {
+		f.thumbnails = make([]*ebiten.Image, len(f.Floors))
+	}
+	if f.thumbnails[floor] == nil {
+		m := f.Floors[floor]
+		img := ebiten.NewImage(m.Width*scale, m.Height*scale)
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				vector.DrawFilledRect(img, float32(x*scale), float32(y*scale), float32(scale), float32(scale), thumbnailColors[m.GetType(x, y)], false)
+			}
+		}
+		f.thumbnails[floor] = img
+	}
+	return f.thumbnails[floor]
+}
+
+// drawFloors draws an overview of every floor down the right of the screen, top
+// floor first. The floor the marble is on is drawn normally with the marble
+// marked, and the others are dimmed
+func (g *Game) drawFloors(screen *ebiten.Image) {
+	if len(g.floors.Floors) < 2 {
+		return
+	}
+
+	const maxThumbnailWidth = 160
+	scale := max(1, maxThumbnailWidth/max(1, g.gameMap.Width))
+	y := 8
+	for floor := len(g.floors.Floors) - 1; floor >= 0; floor-- {
+		thumbnail := g.floors.Thumbnail(floor, scale)
+		x := g.screenWidth - thumbnail.Bounds().Dx() - 8
+
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(x), float64(y))
+		if floor != g.floors.Current {
+			op.ColorScale.Scale(0.35, 0.35, 0.35, 0.8)
+		}
+		screen.DrawImage(thumbnail, op)
+
+		if floor == g.floors.Current {
+			m := g.gameMap
+			mx := float32(x) + float32((g.marble.X-float64(m.OffsetX))/float64(m.TileSize)*float64(scale))
+			my := float32(y) + float32((g.marble.Y-float64(m.OffsetY))/float64(m.TileSize)*float64(scale))
+			vector.DrawFilledCircle(screen, mx, my, float32(max(2, scale)), g.marble.Color, true)
+		}
+		y += thumbnail.Bounds().Dy() + 4
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const gamepadDeadZone = 0.15 // Stick deflection ignored around the centre, as sticks rarely rest exactly there
+
+// gamepadActions are the gamepad buttons for each action, in ebiten's standard
+// layout. Right-cluster buttons are named by position, so these are X, Y and
+// Start on an Xbox pad
+var gamepadActions = []struct {
+	button ebiten.StandardGamepadButton
+	action Action
+}{
+	{ebiten.StandardGamepadButtonRightLeft, ActionReset},
+	{ebiten.StandardGamepadButtonRightTop, ActionNewMaze},
+	{ebiten.StandardGamepadButtonCenterRight, ActionPause},
+}
+
+// GamepadInput tilts the board with the left stick of any connected gamepad, in
+// proportion to how far it's pushed past the dead zone, or fully with the d-pad.
+// Gamepads can be plugged in and unplugged at any time. Only gamepads with the
+// standard layout are used, so the bindings are the same whichever gamepad it is
+type GamepadInput struct {
+	gamepads []ebiten.GamepadID
+}
+
+// updateGamepads keeps track of gamepads as they're plugged in and unplugged
+func (p *GamepadInput) updateGamepads() {
+	for _, id := range inpututil.AppendJustConnectedGamepadIDs(nil) {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			log.Printf("Gamepad %s connected, but it has no standard layout so can't be used", ebiten.GamepadName(id))
+			continue
+		}
+		log.Printf("Gamepad %s connected", ebiten.GamepadName(id))
+		p.gamepads = append(p.gamepads, id)
+	}
+	p.gamepads = slices.DeleteFunc(p.gamepads, func(id ebiten.GamepadID) bool {
+		if inpututil.IsGamepadJustDisconnected(id) {
+			log.Printf("Gamepad %d disconnected", id)
+			return true
+		}
+		return false
+	})
+}
+
+// Poll adds up the tilt from every gamepad, and the actions whose buttons were just pressed
+func (p *GamepadInput) Poll() InputFrame {
+	p.updateGamepads()
+
+	var frame InputFrame
+	for _, id := range p.gamepads {
+		stickX := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+		stickY := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+		// The dead zone is round, and the rest of the stick's travel is
+		// rescaled so tilt still starts from zero at its edge
+		if length := math.Hypot(stickX, stickY); length > gamepadDeadZone {
+			scale := min(1, (length-gamepadDeadZone)/(1-gamepadDeadZone)) / length
+			frame.TiltX += stickX * scale
+			frame.TiltY += stickY * scale
+			frame.Tilting = true
+		}
+
+		dpad := func(button ebiten.StandardGamepadButton) float64 {
+			if ebiten.IsStandardGamepadButtonPressed(id, button) {
+				frame.Tilting = true
+				return 1
+			}
+			return 0
+		}
+		frame.TiltX += dpad(ebiten.StandardGamepadButtonLeftRight) - dpad(ebiten.StandardGamepadButtonLeftLeft)
+		frame.TiltY += dpad(ebiten.StandardGamepadButtonLeftBottom) - dpad(ebiten.StandardGamepadButtonLeftTop)
+
+		for _, binding := range gamepadActions {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, binding.button) {
+				frame.Actions |= binding.action
+			}
+		}
+	}
+	frame.TiltX = min(1, max(-1, frame.TiltX))
+	frame.TiltY = min(1, max(-1, frame.TiltY))
+	return frame
+}
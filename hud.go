@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// startSeedEntry begins typing in a maze seed
+func (g *Game) startSeedEntry() {
+	g.enteringSeed = true
+	g.seedInput = ""
+}
+
+// updateSeedEntry handles keyboard input while a seed is being typed in.
+// Enter regenerates the maze from the typed seed, Escape cancels
+func (g *Game) updateSeedEntry() {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if (r >= '0' && r <= '9') || (r == '-' && g.seedInput == "") {
+			g.seedInput += string(r)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.seedInput) > 0 {
+		g.seedInput = g.seedInput[:len(g.seedInput)-1]
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.enteringSeed = false
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter) {
+		g.enteringSeed = false
+		seed, err := strconv.ParseInt(g.seedInput, 10, 64)
+		if err != nil {
+			return // Leave the current maze alone
+		}
+		g.mazeOptions.Seed = seed
+		g.generateNewMaze()
+	}
+}
+
+// drawHUD draws the on-screen information over the top of the game
+func (g *Game) drawHUD(screen *ebiten.Image) {
+	status := fmt.Sprintf("Seed: %d  Size: %dx%d  Algorithm: %s  Difficulty: %.0f",
+		g.mazeOptions.Seed, g.gameMap.Width, g.gameMap.Height, g.mazeOptions.Algorithm, g.difficulty.Score)
+	if g.mazeBraid != g.mazeOptions.BraidFraction {
+		status += fmt.Sprintf("  Braid: %.0f%%", g.mazeBraid*100)
+	}
+	if g.endless != nil {
+		status = fmt.Sprintf("Endless  Seed: %d  Distance: %d", g.mazeOptions.Seed, g.endless.Score())
+	}
+	if g.daily != nil {
+		status = fmt.Sprintf("Daily %s  Seed: %d  Time: %s  Attempts: %d",
+			g.daily.Date, g.daily.Seed, formatTime(g.daily.Elapsed()), g.daily.Record.Attempts)
+		if g.daily.Record.BestTime > 0 {
+			status += "  Best: " + formatTime(g.daily.Record.BestTime)
+		}
+	}
+	if floors := len(g.floors.Floors); floors > 1 {
+		status += fmt.Sprintf("  Floor: %d/%d", g.floors.Current+1, floors)
+	}
+	if g.totalPellets > 0 {
+		status += fmt.Sprintf("  Pellets: %d/%d", g.pellets, g.totalPellets)
+	}
+	if g.paused {
+		status += "  PAUSED (P or Start to resume)"
+	}
+	if g.enteringSeed {
+		status = fmt.Sprintf("Enter seed: %s_  (Enter to generate, Esc to cancel)", g.seedInput)
+	}
+	ebitenutil.DebugPrintAt(screen, status, 8, 8)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	maxTiltForce          = 0.2      // Force on the marble when the board is tilted fully
+	orientationForce      = 0.5 / 90 // Force on the marble per degree the device is tilted
+	orientationTimeout    = 10       // Ticks without an orientation event before the device stops tilting the board
+	touchFullTiltDistance = 160.0    // Pixels from the marble a touch has to be to tilt the board fully
+)
+
+// orientationChannel is a buffered channel for orientation events - from events_wasm.go
+var orientationChannel = make(chan OrientationEvent, 10)
+
+// OrientationEvent represents device orientation data
+type OrientationEvent struct {
+	Alpha float64 // Z-axis rotation (compass heading)
+	Beta  float64 // X-axis rotation (front-to-back tilt)
+	Gamma float64 // Y-axis rotation (left-to-right tilt)
+}
+
+// Action is something the player asks the game to do, other than tilting the
+// board. Actions are bit flags, so a frame of input can hold several
+type Action uint
+
+const (
+	ActionReset     Action = 1 << iota // Put the marble back at the start
+	ActionNewMaze                      // Start a new maze of the same kind
+	ActionPause                        // Pause or unpause
+	ActionEndless                      // Switch endless mode on/off
+	ActionDaily                        // Switch the daily challenge on/off
+	ActionMenu                         // Open the maze generation menu
+	ActionSeedEntry                    // Type in a seed
+	ActionGrow                         // Grow the maze
+	ActionShrink                       // Shrink the maze
+	ActionZoomIn                       // Zoom the camera in
+	ActionZoomOut                      // Zoom the camera out
+)
+
+// InputFrame is the input from one source for a single tick
+type InputFrame struct {
+	TiltX, TiltY float64 // How far the board is tilted, from -1 to 1 on each axis. Tilting a device can go further
+	Tilting      bool    // Whether the source is tilting the board at all, even if level
+	Actions      Action  // Actions asked for this tick
+}
+
+// Has checks if the frame asks for an action
+func (f InputFrame) Has(action Action) bool {
+	return f.Actions&action != 0
+}
+
+// InputSource is anything the player can control the game with. It's polled
+// once a tick
+type InputSource interface {
+	Poll() InputFrame
+}
+
+// combineInput polls every source and combines their input. The tilt comes
+// from the first source in the list that's tilting, so sources are listed
+// highest priority first, and the actions of every source are kept
+func combineInput(sources []InputSource) InputFrame {
+	var combined InputFrame
+	for _, source := range sources {
+		frame := source.Poll()
+		if frame.Tilting && !combined.Tilting {
+			combined.TiltX, combined.TiltY = frame.TiltX, frame.TiltY
+			combined.Tilting = true
+		}
+		combined.Actions |= frame.Actions
+	}
+	return combined
+}
+
+// clampTilt limits a tilt vector to a length of one, keeping its direction
+func clampTilt(x, y float64) (float64, float64) {
+	if length := math.Hypot(x, y); length > 1 {
+		return x / length, y / length
+	}
+	return x, y
+}
+
+// KeyboardInput tilts the board fully with the arrow keys or WASD, and has a
+// key for each action
+type KeyboardInput struct{}
+
+// keyActions are the keys for each action
+var keyActions = []struct {
+	keys   []ebiten.Key
+	action Action
+}{
+	{[]ebiten.Key{ebiten.KeyR}, ActionReset},
+	{[]ebiten.Key{ebiten.KeyP}, ActionPause},
+	{[]ebiten.Key{ebiten.KeyN}, ActionEndless},
+	{[]ebiten.Key{ebiten.KeyC}, ActionDaily},
+	{[]ebiten.Key{ebiten.KeyM}, ActionMenu},
+	{[]ebiten.Key{ebiten.KeyE}, ActionSeedEntry},
+	{[]ebiten.Key{ebiten.KeyBracketRight}, ActionGrow},
+	{[]ebiten.Key{ebiten.KeyBracketLeft}, ActionShrink},
+	{[]ebiten.Key{ebiten.KeyEqual, ebiten.KeyNumpadAdd}, ActionZoomIn},
+	{[]ebiten.Key{ebiten.KeyMinus, ebiten.KeyNumpadSubtract}, ActionZoomOut},
+}
+
+// Poll reads which tilt and action keys are held or were just pressed
+func (KeyboardInput) Poll() InputFrame {
+	var frame InputFrame
+	tilt := func(a, b ebiten.Key) float64 {
+		if ebiten.IsKeyPressed(a) || ebiten.IsKeyPressed(b) {
+			frame.Tilting = true
+			return 1
+		}
+		return 0
+	}
+	frame.TiltX = tilt(ebiten.KeyArrowRight, ebiten.KeyD) - tilt(ebiten.KeyArrowLeft, ebiten.KeyA)
+	frame.TiltY = tilt(ebiten.KeyArrowDown, ebiten.KeyS) - tilt(ebiten.KeyArrowUp, ebiten.KeyW)
+
+	for _, binding := range keyActions {
+		for _, key := range binding.keys {
+			if inpututil.IsKeyJustPressed(key) {
+				frame.Actions |= binding.action
+			}
+		}
+	}
+	return frame
+}
+
+// OrientationInput tilts the board as the device is tilted, from the
+// orientation events the web page sends. The board stays tilted as it was
+// in the latest event until the next one arrives, unless events stop coming
+type OrientationInput struct {
+	latest OrientationEvent
+	fresh  int // Ticks the latest event still counts for
+}
+
+// Poll takes in the orientation events sent since the last tick, and tilts the board by the latest
+func (o *OrientationInput) Poll() InputFrame {
+	o.fresh = max(0, o.fresh-1)
+	for drained := false; !drained; {
+		select {
+		case event := <-orientationChannel:
+			o.latest = event
+			o.fresh = orientationTimeout
+		default:
+			drained = true
+		}
+	}
+	if o.fresh == 0 {
+		return InputFrame{}
+	}
+	// Gamma is left-right tilt and beta front-back, both in degrees. The
+	// device can tilt the board further than the keys, as it always could
+	x := o.latest.Gamma * orientationForce / maxTiltForce
+	y := o.latest.Beta * orientationForce / maxTiltForce
+	return InputFrame{TiltX: x, TiltY: y, Tilting: true}
+}
+
+// TouchInput tilts the board towards wherever the screen is being touched,
+// further the further the touch is from the marble
+type TouchInput struct {
+	Marble func() (float64, float64) // Where the marble is on screen
+}
+
+// Poll tilts the board towards the first touch on the screen, if there is one
+func (t *TouchInput) Poll() InputFrame {
+	touches := ebiten.AppendTouchIDs(nil)
+	if len(touches) == 0 {
+		return InputFrame{}
+	}
+	touchX, touchY := ebiten.TouchPosition(touches[0])
+	marbleX, marbleY := t.Marble()
+	x, y := clampTilt((float64(touchX)-marbleX)/touchFullTiltDistance, (float64(touchY)-marbleY)/touchFullTiltDistance)
+	return InputFrame{TiltX: x, TiltY: y, Tilting: true}
+}
+
+// ScriptedInput plays back a fixed sequence of input frames, one a tick, to
+// drive the game without a player, such as for demos or tests. Once it runs
+// out it gives no input
+type ScriptedInput struct {
+	Frames []InputFrame
+	next   int
+}
+
+// Poll returns the next frame of the script
+func (s *ScriptedInput) Poll() InputFrame {
+	if s.Done() {
+		return InputFrame{}
+	}
+	s.next++
+	return s.Frames[s.next-1]
+}
+
+// Done checks if every frame has been played back
+func (s *ScriptedInput) Done() bool {
+	return s.next >= len(s.Frames)
+}
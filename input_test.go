@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestCombineInputPriority(t *testing.T) {
+	first := &ScriptedInput{Frames: []InputFrame{
+		{},
+		{TiltX: -0.5, Tilting: true},
+	}}
+	second := &ScriptedInput{Frames: []InputFrame{
+		{TiltX: 1, TiltY: 0.25, Tilting: true},
+		{TiltX: 1, Tilting: true},
+	}}
+	sources := []InputSource{first, second}
+
+	// Only the second source is tilting, so its tilt is used
+	frame := combineInput(sources)
+	if !frame.Tilting || frame.TiltX != 1 || frame.TiltY != 0.25 {
+		t.Errorf("tick 1: got tilt %+v, want the second source's", frame)
+	}
+
+	// Both are tilting, so the first one wins
+	frame = combineInput(sources)
+	if !frame.Tilting || frame.TiltX != -0.5 || frame.TiltY != 0 {
+		t.Errorf("tick 2: got tilt %+v, want the first source's", frame)
+	}
+
+	// Both have run out
+	if frame = combineInput(sources); frame.Tilting || !first.Done() || !second.Done() {
+		t.Errorf("tick 3: got %+v after the scripts ran out, want no input", frame)
+	}
+}
+
+func TestCombineInputMergesActions(t *testing.T) {
+	sources := []InputSource{
+		&ScriptedInput{Frames: []InputFrame{{Actions: ActionReset, TiltY: 1, Tilting: true}}},
+		&ScriptedInput{Frames: []InputFrame{{Actions: ActionPause | ActionZoomIn}}},
+		&ScriptedInput{Frames: []InputFrame{{}}},
+	}
+	frame := combineInput(sources)
+	for _, action := range []Action{ActionReset, ActionPause, ActionZoomIn} {
+		if !frame.Has(action) {
+			t.Errorf("action %d from one of the sources is missing", action)
+		}
+	}
+	if frame.Has(ActionNewMaze) {
+		t.Errorf("got action %d, which no source asked for", ActionNewMaze)
+	}
+}
+
+func TestOrientationInputTimesOut(t *testing.T) {
+	o := &OrientationInput{}
+	if frame := o.Poll(); frame.Tilting {
+		t.Fatalf("got %+v before any orientation event, want no tilt", frame)
+	}
+
+	orientationChannel <- OrientationEvent{Beta: 18, Gamma: -36}
+	frame := o.Poll()
+	if !frame.Tilting || frame.TiltX != -36*orientationForce/maxTiltForce || frame.TiltY != 18*orientationForce/maxTiltForce {
+		t.Errorf("got %+v after an event, want tilt in proportion to it", frame)
+	}
+
+	// The reading holds between events, then lapses
+	for tick := 1; tick < orientationTimeout; tick++ {
+		if frame := o.Poll(); !frame.Tilting {
+			t.Fatalf("tilt stopped %d ticks after the event, want it held for %d", tick, orientationTimeout)
+		}
+	}
+	if frame := o.Poll(); frame.Tilting {
+		t.Errorf("got %+v %d ticks after the last event, want no tilt", frame, orientationTimeout)
+	}
+}
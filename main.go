@@ -4,9 +4,11 @@ import (
 	"embed"
 	"image/color"
 	"log"
+	"math"
+	"os"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
@@ -17,70 +19,126 @@ const (
 //go:embed assets/*
 var assetsFS embed.FS
 
-// orientationChannel is a buffered channel for orientation events - from events_wasm.go
-var orientationChannel = make(chan OrientationEvent, 10)
-
-// OrientationEvent represents device orientation data
-type OrientationEvent struct {
-	Alpha float64 // Z-axis rotation (compass heading)
-	Beta  float64 // X-axis rotation (front-to-back tilt)
-	Gamma float64 // Y-axis rotation (left-to-right tilt)
-}
-
 // Game represents the main game state
 type Game struct {
 	marble                    *Marble
-	gameMap                   *GameMap
+	gameMap                   *GameMap        // Floor the marble is on
+	floors                    *FloorMap       // Every floor of the current level
+	endless                   *EndlessMap     // The endless maze being played, or nil when playing a single maze
+	daily                     *DailyChallenge // Today's challenge being played, or nil
+	onRamp                    bool            // Whether the marble was on a ramp last tick, so it only climbs once per visit
 	grassSpriteSheet          *SpriteSheet
-	stoneSpriteSheet          *SpriteSheet
+	wallTiler                 *AutoTiler
+	tileImages                map[TileType]*ebiten.Image // Generated images for tiles that aren't in a sprite sheet
+	bridgeDecks               map[TileType]*ebiten.Image // Bridge decks on their own, to draw over a marble rolling under them
+	camera                    *Camera
 	screenWidth, screenHeight int
+	mazeOptions               MazeOptions // How new mazes are generated
+	menu                      generationMenu
+	startX, startY            float64          // Where the marble starts on the current map
+	laidOut                   bool             // Whether Layout has seen the real screen size yet
+	level                     int              // Number of mazes completed, for campaign difficulty
+	difficulty                DifficultyReport // How hard the current maze is
+	mazeBraid                 float64          // Fraction of dead ends removed from the current maze, which the difficulty search may have changed
+	enteringSeed              bool             // Whether a seed is being typed in
+	seedInput                 string           // Seed typed so far
+	paused                    bool             // Whether the game is paused
+	pellets, totalPellets     int              // Pellets collected so far, out of how many the level started with
+	inputs                    []InputSource    // What the game is controlled with, highest priority first
 }
 
 // Update proceeds the game state.
 // Update is called every tick (1/60 [s] by default).
 func (g *Game) Update() error {
-	// Handle device orientation events (for mobile/web)
-	select {
-	case event := <-orientationChannel:
-		// Convert gamma (left-right tilt) to horizontal force
-		// Gamma ranges from -90 to 90 degrees
-		gammaForce := event.Gamma / 90.0 * 0.5 // Scale to reasonable force
-
-		// Convert beta (front-back tilt) to vertical force
-		// Beta ranges from -180 to 180 degrees, but we'll use -90 to 90
-		betaForce := event.Beta / 90.0 * 0.5 // Scale to reasonable force
-
-		// Apply orientation forces
-		g.marble.AddForce(gammaForce, betaForce)
-	default:
-		// No orientation event, use keyboard input for tilt mechanics
+	// Sources are polled every tick, even when their input isn't used, so
+	// none of them miss anything like a gamepad being plugged in
+	input := combineInput(g.inputs)
+
+	// While a seed is being typed or the menu is open, the keyboard belongs to them
+	if g.enteringSeed {
+		g.updateSeedEntry()
+		return nil
+	}
+	if g.menu.open {
+		g.updateMenu()
+		return nil
+	}
+	if g.daily != nil && g.daily.finished {
+		g.updateDailyResult(input)
+		return nil
+	}
+
+	if input.Has(ActionPause) {
+		g.paused = !g.paused
+	}
+	if g.paused {
+		return nil
 	}
 
-	// Handle keyboard input for tilt mechanics
-	tiltForce := 0.2
+	// Tilt the board
+	g.marble.AddForce(input.TiltX*maxTiltForce, input.TiltY*maxTiltForce)
 
-	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
-		g.marble.AddForce(-tiltForce, 0)
+	// Reset marble position
+	if input.Has(ActionReset) {
+		if g.endless != nil {
+			g.startEndless() // The start has been discarded, so begin a new run
+		} else {
+			g.resetMarble()
+		}
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
-		g.marble.AddForce(tiltForce, 0)
+
+	// Start a new maze of the same kind
+	if input.Has(ActionNewMaze) {
+		g.mazeOptions.Seed = newSeed()
+		if g.endless != nil {
+			g.startEndless()
+		} else {
+			g.generateNewMaze()
+		}
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) || ebiten.IsKeyPressed(ebiten.KeyW) {
-		g.marble.AddForce(0, -tiltForce)
+
+	// Switch between single mazes and endless mode
+	if input.Has(ActionEndless) {
+		if g.endless != nil {
+			g.generateNewMaze()
+		} else {
+			g.startEndless()
+		}
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyArrowDown) || ebiten.IsKeyPressed(ebiten.KeyS) {
-		g.marble.AddForce(0, tiltForce)
+
+	// Switch the daily challenge on/off
+	if input.Has(ActionDaily) {
+		if g.daily != nil {
+			g.generateNewMaze()
+		} else {
+			g.startDaily()
+		}
 	}
 
-	// Reset marble position if R is pressed
-	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
-		g.marble.SetPosition(640, 360) // Center of screen
-		g.marble.SetVelocity(0, 0)
+	// Open the maze generation menu
+	if input.Has(ActionMenu) {
+		g.openMenu()
 	}
 
-	// Generate new maze if M is pressed
-	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
-		g.generateNewMaze()
+	// Type in a seed to regenerate a specific maze
+	if input.Has(ActionSeedEntry) {
+		g.startSeedEntry()
+	}
+
+	// Grow/shrink the maze
+	if input.Has(ActionGrow) {
+		g.resizeMaze(16, 8)
+	}
+	if input.Has(ActionShrink) {
+		g.resizeMaze(-16, -8)
+	}
+
+	// Zoom in/out
+	if input.Has(ActionZoomIn) {
+		g.camera.SetZoom(g.camera.Zoom * 1.25)
+	}
+	if input.Has(ActionZoomOut) {
+		g.camera.SetZoom(g.camera.Zoom / 1.25)
 	}
 
 	// Update marble physics and get proposed new position
@@ -93,6 +151,48 @@ func (g *Game) Update() error {
 	// Apply tile effects (speed changes)
 	g.gameMap.ApplyTileEffects(g.marble)
 
+	// Roll over pellets to collect them
+	if g.gameMap.CollectPellet(g.marble) {
+		g.pellets++
+	}
+
+	// Endless mazes grow as the marble goes
+	if g.endless != nil {
+		g.endless.Update(g.marble)
+	}
+
+	// The daily challenge is against the clock
+	if g.daily != nil {
+		g.updateDaily(input)
+	}
+
+	// Falling in a hole means starting again
+	if g.gameMap.FellInHole(g.marble) {
+		g.resetMarble()
+	}
+
+	// Rolling onto a ramp moves the marble to the floor above or below
+	ramp := g.gameMap.RampAt(g.marble)
+	if ramp != 0 && !g.onRamp {
+		g.gameMap = g.floors.Climb(ramp)
+	}
+	g.onRamp = ramp != 0
+
+	// Reaching the goal finishes the daily challenge, or moves on to a new maze
+	if g.gameMap.ReachedGoal(g.marble) {
+		if g.daily != nil {
+			g.daily.Finish()
+		} else {
+			log.Printf("Maze %d complete!", g.mazeOptions.Seed)
+			g.level++
+			g.mazeOptions.Seed = newSeed()
+			g.generateNewMaze()
+		}
+	}
+
+	// Keep the marble in view
+	g.camera.Follow(g.marble.X, g.marble.Y, g.gameMap.Bounds())
+
 	return nil
 }
 
@@ -106,53 +206,105 @@ func createTileImage(tileColor color.Color) *ebiten.Image {
 	return img
 }
 
+// createTileImages creates the images for tiles that aren't in a sprite sheet
+func createTileImages(grass *SpriteSheet) map[TileType]*ebiten.Image {
+	hole := ebiten.NewImage(tileSize, tileSize)
+	hole.DrawImage(grass.GetTileImageByCoord(0, 0), nil)
+	vector.DrawFilledCircle(hole, tileSize/2, tileSize/2, tileSize/2-2, color.RGBA{10, 10, 10, 255}, true)
+
+	pellet := ebiten.NewImage(tileSize, tileSize)
+	vector.DrawFilledCircle(pellet, tileSize/2, tileSize/2, 5, color.RGBA{255, 220, 80, 255}, true)
+
+	flowers := ebiten.NewImage(tileSize, tileSize)
+	for _, p := range []struct{ x, y float32 }{{8, 10}, {22, 7}, {15, 22}, {26, 24}} {
+		vector.DrawFilledCircle(flowers, p.x, p.y, 2.5, color.RGBA{240, 240, 255, 255}, true)
+		vector.DrawFilledCircle(flowers, p.x, p.y, 1, color.RGBA{250, 200, 60, 255}, true)
+	}
+
+	pebbles := ebiten.NewImage(tileSize, tileSize)
+	for _, p := range []struct{ x, y, r float32 }{{9, 20, 2.5}, {20, 12, 2}, {24, 25, 1.5}} {
+		vector.DrawFilledCircle(pebbles, p.x, p.y, p.r, color.RGBA{150, 150, 140, 255}, true)
+	}
+
+	return map[TileType]*ebiten.Image{
+		TileSlow:     createTileImage(color.RGBA{100, 50, 50, 255}),  // Red slow tile
+		TileFast:     createTileImage(color.RGBA{50, 100, 50, 255}),  // Green fast tile
+		TileSlowMild: createTileImage(color.RGBA{80, 50, 60, 255}),   // Light red mild slow tile
+		TileFastMild: createTileImage(color.RGBA{50, 80, 60, 255}),   // Light green mild fast tile
+		TileGoal:     createTileImage(color.RGBA{220, 180, 40, 255}), // Gold goal tile
+		TileHole:     hole,
+		TilePellet:   pellet,
+		TileFlowers:  flowers,
+		TilePebbles:  pebbles,
+		TileRampUp:   createRampImage(grass, true),
+		TileRampDown: createRampImage(grass, false),
+		TileBridgeNS: createBridgeImage(grass, TileBridgeNS),
+		TileBridgeEW: createBridgeImage(grass, TileBridgeEW),
+	}
+}
+
+// createBridgeDeck draws the deck of a bridge on its own, running across the
+// tile in the bridge's direction with a railing down each side
+func createBridgeDeck(bridge TileType) *ebiten.Image {
+	img := ebiten.NewImage(tileSize, tileSize)
+	vector.DrawFilledRect(img, 2, 0, tileSize-4, tileSize, color.RGBA{140, 100, 60, 255}, false)
+	for x := float32(2); x < tileSize-2; x += 6 {
+		vector.StrokeLine(img, x, 0, x, tileSize, 1, color.RGBA{110, 75, 45, 255}, false)
+	}
+	vector.DrawFilledRect(img, 0, 0, 3, tileSize, color.RGBA{80, 55, 35, 255}, false)
+	vector.DrawFilledRect(img, tileSize-3, 0, 3, tileSize, color.RGBA{80, 55, 35, 255}, false)
+	if bridge == TileBridgeNS {
+		return img
+	}
+
+	// Turn it on its side
+	rotated := ebiten.NewImage(tileSize, tileSize)
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-tileSize/2, -tileSize/2)
+	op.GeoM.Rotate(math.Pi / 2)
+	op.GeoM.Translate(tileSize/2, tileSize/2)
+	rotated.DrawImage(img, op)
+	return rotated
+}
+
+// createBridgeImage draws a bridge over the grass of the corridor underneath
+func createBridgeImage(grass *SpriteSheet, bridge TileType) *ebiten.Image {
+	img := ebiten.NewImage(tileSize, tileSize)
+	img.DrawImage(grass.GetTileImageByCoord(0, 0), nil)
+	img.DrawImage(createBridgeDeck(bridge), nil)
+	return img
+}
+
+// createRampImage draws a flight of steps over grass, lit towards the top of a
+// ramp up and shadowed towards the bottom of a ramp down
+func createRampImage(grass *SpriteSheet, up bool) *ebiten.Image {
+	img := ebiten.NewImage(tileSize, tileSize)
+	img.DrawImage(grass.GetTileImageByCoord(0, 0), nil)
+	const steps = 4
+	for i := 0; i < steps; i++ {
+		shade := uint8(90 + i*40)
+		if !up {
+			shade = uint8(210 - i*40)
+		}
+		y := float32(tileSize - (i+1)*tileSize/steps)
+		vector.DrawFilledRect(img, 3, y+1, tileSize-6, tileSize/steps-2, color.RGBA{shade, shade, shade + 20, 255}, false)
+	}
+	return img
+}
+
 // getTileImageCallback returns the appropriate tile image for the given coordinates
-func (g *Game) getTileImageCallback(m *GameMap, x, y int) *ebiten.Image {
+func (g *Game) getTileImageCallback(m *GameMap, layer *MapLayer, x, y int) *ebiten.Image {
+	if layer.Name != LayerGround {
+		// Objects & decorations are drawn over the ground, so only need their own image
+		return g.tileImages[layer.GetType(x, y)]
+	}
+
 	switch m.GetType(x, y) {
 	case TileWall:
-		// Use stone spritesheet with smart wall selection
-		// Check if there's a non-wall to the right
-		solidAround := []bool{
-			m.IsSolid(x-1, y-1),
-			m.IsSolid(x, y-1),
-			m.IsSolid(x+1, y-1),
-			m.IsSolid(x-1, y),
-			m.IsSolid(x, y),
-			m.IsSolid(x+1, y),
-			m.IsSolid(x-1, y+1),
-			m.IsSolid(x, y+1),
-			m.IsSolid(x+1, y+1),
-		}
-		if !solidAround[1] && !solidAround[3] && !solidAround[5] && !solidAround[7] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(3, 3)
-		} else if !solidAround[1] && !solidAround[3] && !solidAround[5] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(0, 3)
-		} else if !solidAround[1] && !solidAround[5] && !solidAround[7] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(3, 2)
-		} else if !solidAround[1] && !solidAround[3] && !solidAround[7] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(3, 0)
-		} else if !solidAround[1] && !solidAround[7] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(3, 1)
-		} else if !solidAround[3] && !solidAround[5] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(1, 3)
-		} else if !solidAround[7] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(2, 1) // Wall with grass below
-		} else if !solidAround[5] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(1, 2)
-		} else if !solidAround[3] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(1, 0)
-		} else if !solidAround[1] {
-			return g.stoneSpriteSheet.GetTileImageByCoord(0, 1)
-		}
-		return g.stoneSpriteSheet.GetTileImageByCoord(1, 1) // Regular wall
-	case TileSlow:
-		return createTileImage(color.RGBA{100, 50, 50, 255}) // Red slow tile
-	case TileFast:
-		return createTileImage(color.RGBA{50, 100, 50, 255}) // Green fast tile
-	case TileSlowMild:
-		return createTileImage(color.RGBA{80, 50, 60, 255}) // Light red mild slow tile
-	case TileFastMild:
-		return createTileImage(color.RGBA{50, 80, 60, 255}) // Light green mild fast tile
+		// Pick the wall sprite based on which neighbours are also walls
+		return g.wallTiler.TileImage(m, x, y)
+	case TileSlow, TileFast, TileSlowMild, TileFastMild, TileGoal, TileHole, TileRampUp, TileRampDown, TileBridgeNS, TileBridgeEW:
+		return g.tileImages[m.GetType(x, y)]
 	case TileFloor:
 		fallthrough
 	default:
@@ -173,74 +325,225 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{20, 20, 30, 255})
 
 	// Draw the map
-	g.gameMap.Draw(screen, g.getTileImageCallback)
+	g.gameMap.Draw(screen, g.camera, g.getTileImageCallback)
 
-	// Draw the marble
-	g.marble.Draw(screen)
+	// Draw the marble, and any bridges it's under
+	g.marble.Draw(screen, g.camera)
+	g.drawBridgesOver(screen)
+
+	g.drawFloors(screen)
+	g.drawHUD(screen)
+	if g.menu.open {
+		g.drawMenu(screen)
+	}
+	if g.daily != nil && g.daily.finished {
+		g.drawDailyResult(screen)
+	}
+}
+
+// drawBridgesOver draws the decks of the bridges the marble is rolling under
+// on top of it
+func (g *Game) drawBridgesOver(screen *ebiten.Image) {
+	for _, p := range g.gameMap.BridgesOver(g.marble) {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(g.gameMap.OffsetX+p.X*tileSize), float64(g.gameMap.OffsetY+p.Y*tileSize))
+		op.GeoM.Concat(g.camera.GeoM())
+		screen.DrawImage(g.bridgeDecks[g.gameMap.GetType(p.X, p.Y)], op)
+	}
 }
 
 // Layout takes the outside size (e.g., the window size) and returns the (logical) screen size.
-// If you don't have to adjust the screen size with the outside size, just return a fixed size.
+// The logical screen always matches the outside size, so the board re-lays itself out
+// when the window is resized or the device is rotated.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	if outsideWidth > 0 && outsideHeight > 0 && (outsideWidth != g.screenWidth || outsideHeight != g.screenHeight) {
+		g.resizeScreen(outsideWidth, outsideHeight)
+	}
+	g.laidOut = true
 	return g.screenWidth, g.screenHeight
 }
 
-// generateNewMaze creates a new procedural maze and updates the game map
+// resizeScreen adapts the game to a new screen size. The map is re-centred and
+// the marble moved with it, so its position on the map doesn't change
+func (g *Game) resizeScreen(width, height int) {
+	fitWidth, fitHeight := g.screenMazeSize()
+	mazeFitsScreen := g.mazeOptions.Width == fitWidth && g.mazeOptions.Height == fitHeight
+
+	g.screenWidth, g.screenHeight = width, height
+	g.camera.SetViewSize(width, height)
+
+	if !g.laidOut && mazeFitsScreen {
+		// Nothing has been played yet, so start with a maze sized to the real screen
+		g.mazeOptions.Width, g.mazeOptions.Height = g.screenMazeSize()
+		g.generateNewMaze()
+		g.camera.FitZoom(g.gameMap.Bounds())
+		return
+	}
+
+	dx, dy := g.floors.SetScreenSize(width, height)
+	g.marble.SetPosition(g.marble.X+float64(dx), g.marble.Y+float64(dy))
+	g.startX += float64(dx)
+	g.startY += float64(dy)
+	if g.endless == nil {
+		// Endless mazes never fit, so stay zoomed in on the marble
+		g.camera.FitZoom(g.gameMap.Bounds())
+	}
+	g.camera.CenterOn(g.camera.X+float64(dx), g.camera.Y+float64(dy), g.gameMap.Bounds())
+}
+
+// newSeed picks a random seed for a new maze
+func newSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// generateNewMaze creates a new procedural maze from the current seed and updates the game map
 func (g *Game) generateNewMaze() {
-	// Calculate maze dimensions based on screen size and tile size
-	// Assume 32x32 tiles to match the existing setup
-	mazeWidth := (g.screenWidth / tileSize) - 2 // Leave some border space
-	mazeHeight := (g.screenHeight / tileSize) - 2
+	g.endless = nil
+	g.daily = nil
+	g.mazeOptions.MarbleRadius = g.marble.Radius
+	opts := g.mazeOptions
 
 	// Ensure odd dimensions for proper maze structure
-	if mazeWidth%2 == 0 {
-		mazeWidth--
+	if opts.Width%2 == 0 {
+		opts.Width--
+	}
+	if opts.Height%2 == 0 {
+		opts.Height--
 	}
-	if mazeHeight%2 == 0 {
-		mazeHeight--
+
+	var floors []*MazeResult
+	if opts.Floors > 1 {
+		// Difficulty bands are measured on a single floor, so don't apply to stacks of them
+		floors = CreateMultiFloorMaze(opts)
+	} else if band := DifficultyBandByName(opts.Difficulty, g.level); band.Max > 0 {
+		maze, _ := CreateMazeWithDifficulty(opts, band, g.marble.Radius, 20)
+		// Show the seed actually used. The braiding the search settled on is
+		// only shown, so the player's own choice isn't lost
+		g.mazeOptions.Seed = maze.Options.Seed
+		floors = []*MazeResult{maze}
+	} else {
+		floors = []*MazeResult{CreateMazeWithSpecialTiles(opts)}
 	}
+	g.loadFloors(floors)
+}
 
-	mazeLines := CreateMazeWithSpecialTiles(mazeWidth, mazeHeight, 0.15)
+// loadFloors puts the floors of a newly generated level into play, with the marble at the start
+func (g *Game) loadFloors(floors []*MazeResult) {
+	// Convert the floors to a single level string
+	lines := make([][]string, len(floors))
+	for i, floor := range floors {
+		lines[i] = floor.Lines
+	}
 
-	// Convert slice of strings to single string
-	mazeStr := ""
-	for _, line := range mazeLines {
-		mazeStr += line + "\n"
+	// Update the game map with the new maze, starting on the bottom floor
+	g.floors = NewFloorMap(JoinFloors(lines), tileSize, g.screenWidth, g.screenHeight)
+	g.gameMap = g.floors.Floor()
+	g.difficulty = AnalyzeDifficulty(g.gameMap, g.marble.Radius)
+	g.mazeBraid = floors[0].Options.BraidFraction
+	g.pellets, g.totalPellets = 0, 0
+	for _, floor := range g.floors.Floors {
+		g.totalPellets += floor.CountTiles(LayerObjects, TilePellet)
 	}
 
-	// Update the game map with the new maze
-	g.gameMap = NewGameMap(mazeStr, tileSize, g.screenWidth, g.screenHeight)
+	// Put the marble on the start tile, at the far end of the maze from the goal
+	g.startX, g.startY = g.gameMap.StartPosition()
+	g.resetMarble()
+	g.camera.CenterOn(g.startX, g.startY, g.gameMap.Bounds())
+}
 
-	// Reset marble to a safe starting position (top-left open area)
-	startX := float64(g.gameMap.OffsetX + 2*tileSize)
-	startY := float64(g.gameMap.OffsetY + 2*tileSize)
-	g.marble.SetPosition(startX, startY)
+// startEndless starts a run down an endless maze as wide as the screen
+func (g *Game) startEndless() {
+	width, _ := g.screenMazeSize()
+	if width%2 == 0 {
+		width--
+	}
+	g.endless = NewEndlessMap(width, g.mazeOptions.Seed, tileSize, g.screenWidth, g.screenHeight)
+	g.floors = &FloorMap{Floors: []*GameMap{g.endless.Map}}
+	g.gameMap = g.floors.Floor()
+	g.difficulty = DifficultyReport{}
+	g.pellets, g.totalPellets = 0, 0
+
+	g.startX, g.startY = g.gameMap.StartPosition()
+	g.resetMarble()
+	g.camera.SetZoom(1)
+	g.camera.CenterOn(g.startX, g.startY, g.gameMap.Bounds())
+}
+
+// resetMarble puts the marble back on the start tile of the bottom floor, at rest
+func (g *Game) resetMarble() {
+	g.gameMap = g.floors.Climb(-len(g.floors.Floors))
+	g.onRamp = false
+	g.marble.Level = 0
+	g.marble.SetPosition(g.startX, g.startY)
 	g.marble.SetVelocity(0, 0)
 }
 
+// resizeMaze changes the size of generated mazes by the given number of tiles and
+// generates a new maze. Mazes never get smaller than what fits on screen
+func (g *Game) resizeMaze(dw, dh int) {
+	minWidth, minHeight := g.screenMazeSize()
+	g.mazeOptions.Width = max(minWidth, g.mazeOptions.Width+dw)
+	g.mazeOptions.Height = max(minHeight, g.mazeOptions.Height+dh)
+	g.generateNewMaze()
+}
+
+// screenMazeSize returns the maze dimensions that fit on the screen
+func (g *Game) screenMazeSize() (int, int) {
+	// Calculate maze dimensions based on screen size and tile size
+	// Assume 32x32 tiles to match the existing setup
+	mazeWidth := (g.screenWidth / tileSize) - 2 // Leave some border space
+	mazeHeight := (g.screenHeight / tileSize) - 2
+	return mazeWidth, mazeHeight
+}
+
 func main() {
+	// Generate mazes from the command line without opening a window
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		os.Exit(runGenerate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Create a game instance with a marble and map
 	game := &Game{
 		screenWidth:  1280,
 		screenHeight: 720,
 	}
+	game.camera = NewCamera(game.screenWidth, game.screenHeight)
+	game.mazeOptions = MazeOptions{
+		Seed:               newSeed(),
+		Algorithm:          RecursiveBacktracker{}.Name(),
+		SpecialTileDensity: 0.15,
+		HoleClearance:      8,
+	}
+	game.mazeOptions.Width, game.mazeOptions.Height = game.screenMazeSize()
 
 	// Print controls information
 	log.Println("TiltMan Controls:")
 	log.Println("- Arrow keys or WASD: Tilt the board")
 	log.Println("- R: Reset marble position")
-	log.Println("- M: Generate new random maze")
+	log.Println("- M: Open the maze generation menu")
+	log.Println("- E: Enter a seed to regenerate a specific maze")
+	log.Println("- N: Switch endless mode on/off")
+	log.Println("- C: Play today's daily challenge, or leave it")
+	log.Println("- P: Pause")
+	log.Println("- Gamepad: Left stick or d-pad tilts, X resets, Y starts a new maze, Start pauses")
+	log.Println("- [ / ]: Shrink/grow the maze")
+	log.Println("- + / -: Zoom in/out")
 	log.Println("- On mobile: Tilt your device to control the marble!")
 
 	// Load sprite sheets from embedded filesystem (assuming 32x32 tiles)
 	game.grassSpriteSheet = NewSpriteSheetFromFS(assetsFS, "assets/grass.png", tileSize, tileSize)
-	game.stoneSpriteSheet = NewSpriteSheetFromFS(assetsFS, "assets/stone.png", tileSize, tileSize)
+	game.wallTiler = NewAutoTilerFromFS(assetsFS, "assets/stone.autotile.json")
 
 	if game.grassSpriteSheet == nil {
 		log.Fatalf("Warning: Failed to load grass sprite sheet")
 	}
-	if game.stoneSpriteSheet == nil {
-		log.Fatalf("Warning: Failed to load stone sprite sheet")
+	if game.wallTiler == nil {
+		log.Fatalf("Warning: Failed to load stone autotile rules")
+	}
+	game.tileImages = createTileImages(game.grassSpriteSheet)
+	game.bridgeDecks = map[TileType]*ebiten.Image{
+		TileBridgeNS: createBridgeDeck(TileBridgeNS),
+		TileBridgeEW: createBridgeDeck(TileBridgeEW),
 	}
 
 	// Create marble at starting position (adjust to be within the map)
@@ -248,6 +551,13 @@ func main() {
 	startY := float64(2 * tileSize)
 	game.marble = NewMarble(startX, startY, 15, color.RGBA{255, 100, 100, 255})
 
+	game.inputs = []InputSource{
+		KeyboardInput{},
+		&GamepadInput{},
+		&TouchInput{Marble: func() (float64, float64) { return game.camera.WorldToScreen(game.marble.X, game.marble.Y) }},
+		&OrientationInput{},
+	}
+
 	game.generateNewMaze()
 
 	ebiten.SetWindowSize(game.screenWidth, game.screenHeight)
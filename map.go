@@ -1,6 +1,10 @@
 package main
 
 import (
+	"image"
+	"log"
+	"math"
+	"slices"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -16,8 +20,27 @@ const (
 	TileFast
 	TileSlowMild
 	TileFastMild
+	TileEmpty    // Nothing on this layer (objects/decoration only)
+	TilePellet   // Collectable pellet (objects layer)
+	TileFlowers  // Cosmetic flowers (decoration layer)
+	TilePebbles  // Cosmetic pebbles (decoration layer)
+	TileGoal     // Reaching this completes the level
+	TileHole     // Rolling into this sends the marble back to the start
+	TileRampUp   // Leads up to the floor above
+	TileRampDown // Leads down to the floor below
+	TileBridgeNS // Bridge carrying a north-south corridor over an east-west one
+	TileBridgeEW // Bridge carrying an east-west corridor over a north-south one
 )
 
+// Layer names, in the order they are drawn
+const (
+	LayerGround     = "ground"     // Floor material and walls
+	LayerObjects    = "objects"    // Interactive objects such as pellets
+	LayerDecoration = "decoration" // Purely cosmetic overlay
+)
+
+var layerOrder = []string{LayerGround, LayerObjects, LayerDecoration}
+
 // Tile represents a single tile in the map
 type Tile struct {
 	Type   TileType
@@ -26,128 +49,372 @@ type Tile struct {
 	Effect float64 // Speed multiplier for special tiles
 }
 
+// tileDef describes what an ASCII character turns into
+type tileDef struct {
+	layer  string
+	typ    TileType
+	solid  bool
+	effect float64
+}
+
+// tileChars maps level characters to the tile they describe
+var tileChars = map[rune]tileDef{
+	'#': {LayerGround, TileWall, true, 1.0},
+	'.': {LayerGround, TileFloor, false, 1.0},
+	'<': {LayerGround, TileSlow, false, 0.5},      // Slow down marble
+	'>': {LayerGround, TileFast, false, 1.5},      // Speed up marble
+	'(': {LayerGround, TileSlowMild, false, 0.75}, // Mildly slow down marble
+	')': {LayerGround, TileFastMild, false, 1.25}, // Mildly speed up marble
+	'S': {LayerGround, TileFloor, false, 1.0},     // Floor where the marble starts
+	'G': {LayerGround, TileGoal, false, 1.0},
+	'O': {LayerGround, TileHole, false, 1.0},
+	'^': {LayerGround, TileRampUp, false, 1.0},
+	'v': {LayerGround, TileRampDown, false, 1.0},
+	'|': {LayerGround, TileBridgeNS, false, 1.0},
+	'-': {LayerGround, TileBridgeEW, false, 1.0},
+	'o': {LayerObjects, TilePellet, false, 1.0},
+	'*': {LayerDecoration, TileFlowers, false, 1.0},
+	',': {LayerDecoration, TilePebbles, false, 1.0},
+}
+
+// tileDefsByType maps tile types back to their definition
+var tileDefsByType = func() map[TileType]tileDef {
+	defs := map[TileType]tileDef{TileEmpty: {typ: TileEmpty, effect: 1.0}}
+	for _, def := range tileChars {
+		defs[def.typ] = def
+	}
+	return defs
+}()
+
+// bridgeDeck returns the direction the deck of a bridge tile runs in, or false
+// if the tile isn't a bridge
+func bridgeDeck(typ TileType) (image.Point, bool) {
+	switch typ {
+	case TileBridgeNS:
+		return image.Pt(0, 1), true
+	case TileBridgeEW:
+		return image.Pt(1, 0), true
+	}
+	return image.Point{}, false
+}
+
+// alongDeck checks if a step between neighbouring tiles runs along a bridge deck
+// rather than across it
+func alongDeck(deck, step image.Point) bool {
+	return deck.X*step.X+deck.Y*step.Y != 0
+}
+
+// tile creates a tile from its definition at the given grid coordinates
+func (def tileDef) tile(x, y int) Tile {
+	return Tile{
+		Type:   def.typ,
+		X:      x,
+		Y:      y,
+		Solid:  def.solid,
+		Effect: def.effect,
+	}
+}
+
+// MapLayer is a single named grid of tiles. Layers are stacked in a GameMap
+type MapLayer struct {
+	Name     string
+	Tiles    [][]Tile
+	FirstRow int // Grid row held in Tiles[0], as endless maps discard the rows behind the marble
+}
+
 // GameMap represents the game map
 type GameMap struct {
-	Tiles    [][]Tile
-	Width    int // Number of tiles horizontally
-	Height   int // Number of tiles vertically
-	TileSize int // Size of each tile in pixels
-	OffsetX  int // X offset for centering the map
-	OffsetY  int // Y offset for centering the map
+	Layers   []*MapLayer // Layers in draw order, ground first
+	Tiles    [][]Tile    // Ground layer tiles (shared with the ground MapLayer)
+	Width    int         // Number of tiles horizontally
+	Height   int         // Number of rows of tiles held
+	FirstRow int         // Grid row of the first row held. Only endless maps discard rows, see endless.go
+	TileSize int         // Size of each tile in pixels
+	OffsetX  int         // X offset for centering the map
+	OffsetY  int         // Y offset for centering the map
+	Start    image.Point // Grid coordinates the marble starts at
+
+	cache *mapRenderCache // Pre-rendered tiles, see mapcache.go
 }
 
-// NewGameMap creates a new game map from an ASCII string
+// NewGameMap creates a new game map from an ASCII string.
+//
+// The string is either a single grid, or a set of grids each introduced by a
+// "[layer]" header line, eg:
+//
+//	[ground]
+//	#####
+//	#.<.#
+//	#####
+//	[objects]
+//
+//	  o
+//
+// In a single grid, object and decoration characters are lifted into their
+// own layer with floor underneath.
 func NewGameMap(asciiMap string, tileSize int, screenWidth, screenHeight int) *GameMap {
-	lines := strings.Split(strings.TrimSpace(asciiMap), "\n")
-	height := len(lines)
+	sections := parseLayerSections(asciiMap)
+	height := 0
 	width := 0
 
-	// Find the maximum width
-	for _, line := range lines {
-		if len(line) > width {
-			width = len(line)
+	// Find the maximum size over all layers
+	for _, lines := range sections {
+		if len(lines) > height {
+			height = len(lines)
+		}
+		for _, line := range lines {
+			if len(line) > width {
+				width = len(line)
+			}
 		}
 	}
 
-	// Calculate offsets to center the map
-	offsetX := (screenWidth - width*tileSize) / 2
-	offsetY := (screenHeight - height*tileSize) / 2
-
 	gameMap := &GameMap{
-		Tiles:    make([][]Tile, height),
 		Width:    width,
 		Height:   height,
 		TileSize: tileSize,
-		OffsetX:  offsetX,
-		OffsetY:  offsetY,
-	}
-
-	// Parse the ASCII map
-	for y, line := range lines {
-		gameMap.Tiles[y] = make([]Tile, width)
-		for x, char := range line {
-			tile := Tile{X: x, Y: y}
-
-			switch char {
-			case '#':
-				tile.Type = TileWall
-				tile.Solid = true
-				tile.Effect = 1.0
-			case '.':
-				tile.Type = TileFloor
-				tile.Solid = false
-				tile.Effect = 1.0
-			case '<':
-				tile.Type = TileSlow
-				tile.Solid = false
-				tile.Effect = 0.5 // Slow down marble
-			case '>':
-				tile.Type = TileFast
-				tile.Solid = false
-				tile.Effect = 1.5 // Speed up marble
-			case '(':
-				tile.Type = TileSlowMild
-				tile.Solid = false
-				tile.Effect = 0.75 // Mildly slow down marble
-			case ')':
-				tile.Type = TileFastMild
-				tile.Solid = false
-				tile.Effect = 1.25 // Mildly speed up marble
-			default:
-				// Default to floor for unknown characters
-				tile.Type = TileFloor
-				tile.Solid = false
-				tile.Effect = 1.0
+		Start:    image.Pt(1, 1),
+		cache:    newMapRenderCache(),
+	}
+	gameMap.SetScreenSize(screenWidth, screenHeight)
+	for _, name := range layerOrder {
+		gameMap.Layers = append(gameMap.Layers, newMapLayer(name, width, height))
+	}
+	gameMap.Tiles = gameMap.Layers[0].Tiles
+
+	// Parse the ASCII layers in a fixed order, so a named layer section always
+	// overrides the unheaded grid where they overlap
+	for _, name := range append([]string{""}, layerOrder...) {
+		for y, line := range sections[name] {
+			for x, char := range []rune(line) {
+				if char == 'S' {
+					gameMap.Start = image.Pt(x, y)
+				}
+				def, ok := tileChars[char]
+				if !ok {
+					continue // Unknown characters leave the layer default
+				}
+				if name != "" && def.layer != name {
+					continue // Characters only apply to their own layer
+				}
+				gameMap.Layer(def.layer).Tiles[y][x] = def.tile(x, y)
 			}
-
-			gameMap.Tiles[y][x] = tile
 		}
 	}
 
 	return gameMap
 }
 
+// parseLayerSections splits a level into its layer grids, keyed by layer name.
+// A level without any headers is returned under the empty name. Sections for
+// layers that don't exist are skipped with a warning, so they don't add to the
+// size of the map
+func parseLayerSections(asciiMap string) map[string][]string {
+	sections := map[string][]string{}
+	current, skipping := "", false
+	for _, line := range strings.Split(strings.Trim(asciiMap, "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) > 2 && trimmed[0] == '[' && trimmed[len(trimmed)-1] == ']' {
+			current = trimmed[1 : len(trimmed)-1]
+			skipping = !slices.Contains(layerOrder, current)
+			if skipping {
+				log.Printf("Skipping unknown layer section [%s]", current)
+				continue
+			}
+			sections[current] = nil
+			continue
+		}
+		if !skipping {
+			sections[current] = append(sections[current], line)
+		}
+	}
+	if lines, ok := sections[""]; ok && len(sections) > 1 {
+		// Ignore blank lines before the first header
+		if strings.TrimSpace(strings.Join(lines, "")) == "" {
+			delete(sections, "")
+		}
+	}
+	return sections
+}
+
+// newMapLayer creates a layer filled with its default tile: floor for the
+// ground, empty for everything else
+func newMapLayer(name string, width, height int) *MapLayer {
+	layer := &MapLayer{Name: name, Tiles: make([][]Tile, height)}
+	for y := range layer.Tiles {
+		layer.Tiles[y] = newLayerRow(name, width, y)
+	}
+	return layer
+}
+
+// newLayerRow creates a row of a layer filled with its default tile
+func newLayerRow(name string, width, y int) []Tile {
+	def := Tile{Type: TileEmpty, Effect: 1.0}
+	if name == LayerGround {
+		def.Type = TileFloor
+	}
+	row := make([]Tile, width)
+	for x := range row {
+		row[x] = def
+		row[x].X, row[x].Y = x, y
+	}
+	return row
+}
+
+// AppendRows adds rows of ASCII tiles to the bottom of the map, with object and
+// decoration characters lifted into their own layers
+func (m *GameMap) AppendRows(lines []string) {
+	for _, line := range lines {
+		y := m.FirstRow + m.Height
+		for _, layer := range m.Layers {
+			layer.Tiles = append(layer.Tiles, newLayerRow(layer.Name, m.Width, y))
+		}
+		m.Height++
+		for x, char := range []rune(line) {
+			if def, ok := tileChars[char]; ok && x < m.Width {
+				*m.Layer(def.layer).TileAt(x, y) = def.tile(x, y)
+			}
+		}
+
+		// Parts of the new row may already have been drawn as empty
+		for x := 0; x < m.Width; x++ {
+			m.cache.invalidateTile(x, y)
+		}
+	}
+	m.Tiles = m.Layers[0].Tiles
+}
+
+// DiscardRows forgets the first rows of the map, eg: the part of an endless map
+// that's far behind the marble
+func (m *GameMap) DiscardRows(rows int) {
+	rows = min(rows, m.Height)
+	for y := m.FirstRow; y < m.FirstRow+rows; y++ {
+		for x := 0; x < m.Width; x++ {
+			m.cache.invalidateTile(x, y)
+		}
+	}
+	for _, layer := range m.Layers {
+		layer.Tiles = layer.Tiles[rows:]
+		layer.FirstRow += rows
+	}
+	m.FirstRow += rows
+	m.Height -= rows
+	m.Tiles = m.Layers[0].Tiles
+}
+
+// SetScreenSize recalculates the offsets that centre the map on a screen of the
+// given size. Maps larger than the screen start at the origin and rely on the
+// camera to scroll. It returns how far the map moved, so that anything
+// positioned on it can be moved too
+func (m *GameMap) SetScreenSize(screenWidth, screenHeight int) (dx, dy int) {
+	offsetX := max(0, (screenWidth-m.Width*m.TileSize)/2)
+	offsetY := max(0, (screenHeight-m.Height*m.TileSize)/2)
+	dx, dy = offsetX-m.OffsetX, offsetY-m.OffsetY
+	m.OffsetX, m.OffsetY = offsetX, offsetY
+	return dx, dy
+}
+
+// Layer returns the named layer, or nil if the map doesn't have it
+func (m *GameMap) Layer(name string) *MapLayer {
+	for _, layer := range m.Layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return nil
+}
+
+// TileAt returns the tile at the given grid coordinates, or nil if out of bounds
+func (l *MapLayer) TileAt(x, y int) *Tile {
+	y -= l.FirstRow
+	if y < 0 || y >= len(l.Tiles) || x < 0 || x >= len(l.Tiles[y]) {
+		return nil
+	}
+	return &l.Tiles[y][x]
+}
+
+// GetType returns the type of the tile at the given grid coordinates
+func (l *MapLayer) GetType(x, y int) TileType {
+	tile := l.TileAt(x, y)
+	if tile == nil {
+		return TileEmpty
+	}
+	return tile.Type
+}
+
+// GetType returns the ground type at the given grid coordinates
 func (m *GameMap) GetType(x, y int) TileType {
-	if y < 0 || y >= m.Height || x < 0 || x >= m.Width {
+	tile := m.Layers[0].TileAt(x, y)
+	if tile == nil {
 		return TileFloor // Default to floor for out-of-bounds
 	}
-	return m.Tiles[y][x].Type
+	return tile.Type
 }
 
-// GetTileAt returns the tile at the given pixel coordinates
-func (m *GameMap) GetTileAt(pixelX, pixelY float64) *Tile {
-	// Convert pixel coordinates to grid coordinates
-	gridX := int((pixelX - float64(m.OffsetX)) / float64(m.TileSize))
-	gridY := int((pixelY - float64(m.OffsetY)) / float64(m.TileSize))
+// gridCoords converts pixel coordinates to grid coordinates
+func (m *GameMap) gridCoords(pixelX, pixelY float64) (int, int, bool) {
+	gridX := int(math.Floor((pixelX - float64(m.OffsetX)) / float64(m.TileSize)))
+	gridY := int(math.Floor((pixelY - float64(m.OffsetY)) / float64(m.TileSize)))
+	return gridX, gridY, m.inBounds(gridX, gridY)
+}
 
-	// Check bounds
-	if gridX < 0 || gridX >= m.Width || gridY < 0 || gridY >= m.Height {
+// inBounds checks if the map holds the given grid coordinates
+func (m *GameMap) inBounds(x, y int) bool {
+	return x >= 0 && x < m.Width && y >= m.FirstRow && y < m.FirstRow+m.Height
+}
+
+// GetTileAt returns the ground tile at the given pixel coordinates
+func (m *GameMap) GetTileAt(pixelX, pixelY float64) *Tile {
+	gridX, gridY, ok := m.gridCoords(pixelX, pixelY)
+	if !ok {
 		return nil
 	}
 
-	return &m.Tiles[gridY][gridX]
+	return m.Layers[0].TileAt(gridX, gridY)
+}
+
+// GetLayerTileAt returns the tile on the named layer at the given pixel coordinates
+func (m *GameMap) GetLayerTileAt(name string, pixelX, pixelY float64) *Tile {
+	layer := m.Layer(name)
+	gridX, gridY, ok := m.gridCoords(pixelX, pixelY)
+	if layer == nil || !ok {
+		return nil
+	}
+	return layer.TileAt(gridX, gridY)
 }
 
-// IsSolidAt checks if there's a wall at the given pixel coordinates
+// IsSolidAt checks if there's a wall on any layer at the given pixel coordinates
 func (m *GameMap) IsSolidAt(pixelX, pixelY float64) bool {
-	tile := m.GetTileAt(pixelX, pixelY)
-	return tile != nil && tile.Solid
+	gridX, gridY, ok := m.gridCoords(pixelX, pixelY)
+	return ok && m.IsSolid(gridX, gridY)
 }
 
+// IsSolid checks if any layer is solid at the given grid coordinates.
+// Out-of-bounds coordinates are treated as solid
 func (m *GameMap) IsSolid(x, y int) bool {
-	if y < 0 || y >= m.Height || x < 0 || x >= m.Width {
+	if !m.inBounds(x, y) {
 		return true
 	}
-	return m.Tiles[y][x].Solid
+	for _, layer := range m.Layers {
+		if layer.TileAt(x, y).Solid {
+			return true
+		}
+	}
+	return false
 }
 
-// GetEffectAt returns the speed effect at the given pixel coordinates
+// GetEffectAt returns the combined speed effect of all layers at the given pixel coordinates
 func (m *GameMap) GetEffectAt(pixelX, pixelY float64) float64 {
-	tile := m.GetTileAt(pixelX, pixelY)
-	if tile != nil {
-		return tile.Effect
+	gridX, gridY, ok := m.gridCoords(pixelX, pixelY)
+	if !ok {
+		return 1.0 // Default effect
+	}
+	effect := 1.0
+	for _, layer := range m.Layers {
+		effect *= layer.TileAt(gridX, gridY).Effect
 	}
-	return 1.0 // Default effect
+	return effect
 }
 
 // CheckCollision checks for collision with walls and returns corrected position
@@ -170,7 +437,7 @@ func (m *GameMap) CheckCollision(marble *Marble, newX, newY float64) (float64, f
 		checkX := marble.X + point.dx
 		checkY := marble.Y + point.dy
 
-		if m.IsSolidAt(checkX, checkY) {
+		if m.blocksMarble(marble, checkX, checkY) {
 			// Simple collision response - stop movement in the direction of collision
 			if point.dx < 0 && marble.VX < 0 { // Left collision
 				newX = marble.X
@@ -190,10 +457,76 @@ func (m *GameMap) CheckCollision(marble *Marble, newX, newY float64) (float64, f
 		}
 	}
 
+	m.updateLevel(marble, newX, newY)
 	return newX, newY
 }
 
-// ApplyTileEffects applies the effects of the tile the marble is on
+// blocksMarble checks if the marble can't roll over the given point at its
+// current height. As well as walls, a bridge has walls across whichever
+// corridor the marble isn't in: the underpass on the deck, and the deck below it
+func (m *GameMap) blocksMarble(marble *Marble, pixelX, pixelY float64) bool {
+	if m.IsSolidAt(pixelX, pixelY) {
+		return true
+	}
+	bridgeX, bridgeY, _ := m.gridCoords(marble.X, marble.Y)
+	deck, onBridge := bridgeDeck(m.GetType(bridgeX, bridgeY))
+	x, y, ok := m.gridCoords(pixelX, pixelY)
+	if !onBridge || !ok || (x == bridgeX && y == bridgeY) {
+		return false
+	}
+	if _, isBridge := bridgeDeck(m.GetType(x, y)); isBridge {
+		return false // More of the same wide bridge
+	}
+	return alongDeck(deck, image.Pt(x-bridgeX, y-bridgeY)) != (marble.Level > 0)
+}
+
+// updateLevel sets the marble's height as it moves to a new position. Rolling
+// onto a bridge along its deck climbs onto it, rolling on across it goes
+// underneath, and everywhere else is ground level
+func (m *GameMap) updateLevel(marble *Marble, newX, newY float64) {
+	fromX, fromY, _ := m.gridCoords(marble.X, marble.Y)
+	toX, toY, ok := m.gridCoords(newX, newY)
+	deck, onBridge := bridgeDeck(m.GetType(toX, toY))
+	if !ok || !onBridge {
+		marble.Level = 0
+		return
+	}
+	if _, fromBridge := bridgeDeck(m.GetType(fromX, fromY)); fromBridge {
+		return // Still on the same bridge, at the same height
+	}
+	marble.Level = 0
+	if alongDeck(deck, image.Pt(toX-fromX, toY-fromY)) {
+		marble.Level = 1
+	}
+}
+
+// BridgesOver returns the grid coordinates of the bridges the marble is
+// rolling under, or is about to, so they can be drawn on top of it
+func (m *GameMap) BridgesOver(marble *Marble) []image.Point {
+	if marble.Level > 0 {
+		return nil
+	}
+	centreX, centreY, _ := m.gridCoords(marble.X, marble.Y)
+	minX, minY, _ := m.gridCoords(marble.X-marble.Radius, marble.Y-marble.Radius)
+	maxX, maxY, _ := m.gridCoords(marble.X+marble.Radius, marble.Y+marble.Radius)
+	var bridges []image.Point
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			deck, isBridge := bridgeDeck(m.GetType(x, y))
+			if !isBridge || (x != centreX && y != centreY) {
+				continue
+			}
+			// Approaching along the deck means climbing onto it, not going under
+			if step := image.Pt(x-centreX, y-centreY); step == (image.Point{}) || !alongDeck(deck, step) {
+				bridges = append(bridges, image.Pt(x, y))
+			}
+		}
+	}
+	return bridges
+}
+
+// ApplyTileEffects applies the effects of the tile the marble is on. Speeding
+// up can't take the marble past its radius in a tick, so it can't pass through walls
 func (m *GameMap) ApplyTileEffects(marble *Marble) {
 	effect := m.GetEffectAt(marble.X, marble.Y)
 
@@ -202,30 +535,107 @@ func (m *GameMap) ApplyTileEffects(marble *Marble) {
 		marble.VX *= effect
 		marble.VY *= effect
 	}
+	if speed := math.Hypot(marble.VX, marble.VY); effect > 1.0 && speed > marble.Radius {
+		marble.VX *= marble.Radius / speed
+		marble.VY *= marble.Radius / speed
+	}
 }
 
-// TileImageCallback is a function type that returns an image for a given tile coordinate
-type TileImageCallback func(m *GameMap, x, y int) *ebiten.Image
-
-// Draw renders the map to the screen using a callback to get tile images
-func (m *GameMap) Draw(screen *ebiten.Image, getTileImage TileImageCallback) {
-	for y := 0; y < m.Height; y++ {
-		for x := 0; x < m.Width; x++ {
-			// Calculate pixel position
-			pixelX := float64(m.OffsetX + x*m.TileSize)
-			pixelY := float64(m.OffsetY + y*m.TileSize)
-
-			// Get the tile image from the callback
-			tileImage := getTileImage(m, x, y)
+// SetTileType changes the tile on the named layer at the given grid coordinates.
+// Only the affected part of the render cache is redrawn
+func (m *GameMap) SetTileType(name string, x, y int, typ TileType) {
+	layer := m.Layer(name)
+	if layer == nil || layer.TileAt(x, y) == nil {
+		return
+	}
+	*layer.TileAt(x, y) = tileDefsByType[typ].tile(x, y)
 
-			if tileImage != nil {
-				// Draw the tile image, scaling it to fit the tile size
-				options := &ebiten.DrawImageOptions{}
+	// Neighbouring tiles may change appearance too (eg: wall edges)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			m.cache.invalidateTile(x+dx, y+dy)
+		}
+	}
+}
 
-				options.GeoM.Translate(pixelX, pixelY)
+// CollectPellet picks up the pellet under the marble's centre, returning
+// whether there was one
+func (m *GameMap) CollectPellet(marble *Marble) bool {
+	tile := m.GetLayerTileAt(LayerObjects, marble.X, marble.Y)
+	if tile == nil || tile.Type != TilePellet {
+		return false
+	}
+	m.SetTileType(LayerObjects, tile.X, tile.Y, TileEmpty)
+	return true
+}
 
-				screen.DrawImage(tileImage, options)
+// CountTiles returns how many tiles of the given type there are on the named layer
+func (m *GameMap) CountTiles(name string, typ TileType) int {
+	layer := m.Layer(name)
+	if layer == nil {
+		return 0
+	}
+	count := 0
+	for _, row := range layer.Tiles {
+		for _, tile := range row {
+			if tile.Type == typ {
+				count++
 			}
 		}
 	}
+	return count
+}
+
+// StartPosition returns the pixel coordinates of the centre of the start tile
+func (m *GameMap) StartPosition() (float64, float64) {
+	return float64(m.OffsetX + m.Start.X*m.TileSize + m.TileSize/2), float64(m.OffsetY + m.Start.Y*m.TileSize + m.TileSize/2)
+}
+
+// ReachedGoal checks if the marble's centre is over a goal tile
+func (m *GameMap) ReachedGoal(marble *Marble) bool {
+	tile := m.GetTileAt(marble.X, marble.Y)
+	return tile != nil && tile.Type == TileGoal
+}
+
+// IsHole checks if the ground at the given grid coordinates is a hole
+func (m *GameMap) IsHole(x, y int) bool {
+	return m.GetType(x, y) == TileHole
+}
+
+// FellInHole checks if the marble's centre is over a hole
+func (m *GameMap) FellInHole(marble *Marble) bool {
+	tile := m.GetTileAt(marble.X, marble.Y)
+	return tile != nil && tile.Type == TileHole
+}
+
+// RampAt returns which way a ramp under the marble's centre leads: 1 for up a
+// floor, -1 for down, or 0 if it isn't on a ramp
+func (m *GameMap) RampAt(marble *Marble) int {
+	tile := m.GetTileAt(marble.X, marble.Y)
+	switch {
+	case tile == nil:
+		return 0
+	case tile.Type == TileRampUp:
+		return 1
+	case tile.Type == TileRampDown:
+		return -1
+	}
+	return 0
+}
+
+// Bounds returns the area covered by the map in world pixel coordinates
+func (m *GameMap) Bounds() image.Rectangle {
+	top := m.OffsetY + m.FirstRow*m.TileSize
+	return image.Rect(m.OffsetX, top, m.OffsetX+m.Width*m.TileSize, top+m.Height*m.TileSize)
+}
+
+// TileImageCallback is a function type that returns an image for a given tile coordinate on a layer.
+// Its results are cached, so it should return the same image for the same tiles
+type TileImageCallback func(m *GameMap, layer *MapLayer, x, y int) *ebiten.Image
+
+// Draw renders the map to the screen through the camera using a callback to get
+// tile images. Layers are drawn in order, so later layers appear on top. Tiles
+// are pre-rendered in chunks, and only chunks in the camera's view are drawn
+func (m *GameMap) Draw(screen *ebiten.Image, camera *Camera, getTileImage TileImageCallback) {
+	m.cache.draw(m, screen, camera, getTileImage)
 }
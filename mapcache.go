@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	cacheChunkTiles = 16 // Width & height of a cached chunk, in tiles
+	maxCachedChunks = 64 // Chunks kept around before off-screen ones are thrown away
+)
+
+// mapRenderCache holds pre-rendered chunks of a map, so that unchanged tiles
+// aren't redrawn every frame
+type mapRenderCache struct {
+	chunks map[image.Point]*cachedChunk
+}
+
+// cachedChunk is a pre-rendered square of tiles covering all map layers
+type cachedChunk struct {
+	image *ebiten.Image
+	dirty map[image.Point]bool // Tiles (in grid coordinates) that need to be redrawn
+}
+
+func newMapRenderCache() *mapRenderCache {
+	return &mapRenderCache{chunks: map[image.Point]*cachedChunk{}}
+}
+
+// chunkFor returns the chunk coordinate containing the given tile
+func chunkFor(x, y int) image.Point {
+	return image.Pt(floorDiv(x, cacheChunkTiles), floorDiv(y, cacheChunkTiles))
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// invalidateTile marks a single tile as needing a redraw
+func (c *mapRenderCache) invalidateTile(x, y int) {
+	chunk := c.chunks[chunkFor(x, y)]
+	if chunk == nil {
+		return // Not rendered yet, so will be drawn fresh anyway
+	}
+	if chunk.dirty == nil {
+		chunk.dirty = map[image.Point]bool{}
+	}
+	chunk.dirty[image.Pt(x, y)] = true
+}
+
+// draw renders the visible chunks of the map to the screen, bringing them up to date first
+func (c *mapRenderCache) draw(m *GameMap, screen *ebiten.Image, camera *Camera, getTileImage TileImageCallback) {
+	// Work out which tiles are visible
+	visible := camera.VisibleRect().Intersect(m.Bounds())
+	if visible.Empty() {
+		return
+	}
+	minChunk := chunkFor((visible.Min.X-m.OffsetX)/m.TileSize, (visible.Min.Y-m.OffsetY)/m.TileSize)
+	maxChunk := chunkFor((visible.Max.X-m.OffsetX-1)/m.TileSize, (visible.Max.Y-m.OffsetY-1)/m.TileSize)
+	cameraGeoM := camera.GeoM()
+
+	for cy := minChunk.Y; cy <= maxChunk.Y; cy++ {
+		for cx := minChunk.X; cx <= maxChunk.X; cx++ {
+			chunk := c.render(m, image.Pt(cx, cy), getTileImage)
+
+			options := &ebiten.DrawImageOptions{}
+			options.GeoM.Translate(float64(m.OffsetX+cx*cacheChunkTiles*m.TileSize), float64(m.OffsetY+cy*cacheChunkTiles*m.TileSize))
+			options.GeoM.Concat(cameraGeoM)
+			screen.DrawImage(chunk.image, options)
+		}
+	}
+
+	if len(c.chunks) > maxCachedChunks {
+		c.evict(minChunk, maxChunk)
+	}
+}
+
+// render returns the given chunk, creating it or redrawing any dirty tiles as needed
+func (c *mapRenderCache) render(m *GameMap, key image.Point, getTileImage TileImageCallback) *cachedChunk {
+	chunk := c.chunks[key]
+	if chunk == nil {
+		size := cacheChunkTiles * m.TileSize
+		chunk = &cachedChunk{image: ebiten.NewImage(size, size)}
+		c.chunks[key] = chunk
+		for y := 0; y < cacheChunkTiles; y++ {
+			for x := 0; x < cacheChunkTiles; x++ {
+				c.renderTile(m, chunk, key, key.X*cacheChunkTiles+x, key.Y*cacheChunkTiles+y, getTileImage)
+			}
+		}
+		return chunk
+	}
+
+	for tile := range chunk.dirty {
+		// Clear out the old tile before drawing, as upper layers may be transparent
+		localX := (tile.X - key.X*cacheChunkTiles) * m.TileSize
+		localY := (tile.Y - key.Y*cacheChunkTiles) * m.TileSize
+		chunk.image.SubImage(image.Rect(localX, localY, localX+m.TileSize, localY+m.TileSize)).(*ebiten.Image).Clear()
+		c.renderTile(m, chunk, key, tile.X, tile.Y, getTileImage)
+	}
+	chunk.dirty = nil
+	return chunk
+}
+
+// renderTile draws every layer of a single tile into its chunk
+func (c *mapRenderCache) renderTile(m *GameMap, chunk *cachedChunk, key image.Point, x, y int, getTileImage TileImageCallback) {
+	if !m.inBounds(x, y) {
+		return
+	}
+	for _, layer := range m.Layers {
+		tileImage := getTileImage(m, layer, x, y)
+		if tileImage == nil {
+			continue
+		}
+		options := &ebiten.DrawImageOptions{}
+		options.GeoM.Translate(float64((x-key.X*cacheChunkTiles)*m.TileSize), float64((y-key.Y*cacheChunkTiles)*m.TileSize))
+		chunk.image.DrawImage(tileImage, options)
+	}
+}
+
+// evict throws away chunks outside the given visible range
+func (c *mapRenderCache) evict(minChunk, maxChunk image.Point) {
+	for key, chunk := range c.chunks {
+		if key.X < minChunk.X || key.X > maxChunk.X || key.Y < minChunk.Y || key.Y > maxChunk.Y {
+			chunk.image.Deallocate()
+			delete(c.chunks, key)
+		}
+	}
+}
@@ -1,16 +1,45 @@
 package main
 
 import (
+	"image"
+	"log"
+	"math"
 	"math/rand"
-	"time"
 )
 
-// MazeGenerator creates ASCII mazes of arbitrary size
+// MazeGenerator creates ASCII mazes of arbitrary size.
+// Mazes are carved on a logical grid of one-tile corridors and walls, which is
+// then expanded to the corridor width and wall thickness in tiles
 type MazeGenerator struct {
-	width  int
-	height int
-	maze   [][]rune
-	rng    *rand.Rand
+	width         int // Logical grid size
+	height        int
+	tileWidth     int // Requested size in tiles
+	tileHeight    int
+	corridorWidth int
+	wallThickness int
+	maze          [][]byte
+	rng           *rand.Rand
+	algorithm     MazeAlgorithm
+	vaults        []vault // Prefabs reserved by ReservePrefabs, stamped over the maze once it's carved
+}
+
+// MazeOptions describes how to generate a maze
+type MazeOptions struct {
+	Width, Height      int                // Size in tiles
+	Seed               int64              // Random seed; the same options always produce the same maze
+	Algorithm          string             // Name of the MazeAlgorithm to carve with, or of a level generator
+	SpecialTileDensity float64            // Fraction of floor tiles turned into speed tiles
+	TileWeights        SpecialTileWeights // Where speed tiles are placed; the zero value uses DefaultSpecialTileWeights
+	BraidFraction      float64            // Fraction of dead ends removed by joining them to a neighbour
+	LoopDensity        float64            // Chance of knocking out each remaining wall between two cells
+	Difficulty         string             // Name of the difficulty band to aim for, see DifficultyBandByName
+	HoleDensity        float64            // Fraction of floor tiles away from the solution turned into holes
+	HoleClearance      float64            // Smallest gap in pixels between the marble and any hole along the solution
+	MarbleRadius       float64            // Radius in pixels of the marble the solution must be safe for
+	CorridorWidth      int                // Width of the corridors in tiles; zero means one
+	WallThickness      int                // Thickness of the walls between corridors in tiles; zero means one
+	Prefabs            int                // Number of prefab vaults to stamp into mazes; level generators don't use them
+	Floors             int                // Number of floors stacked on top of each other; zero means one
 }
 
 // Direction represents movement directions for maze generation
@@ -26,8 +55,9 @@ var directions = []Direction{
 }
 
 // NewMazeGenerator creates a new maze generator with the specified dimensions
-// Width and height should be odd numbers for proper maze structure
-func NewMazeGenerator(width, height int) *MazeGenerator {
+// Width and height should be odd numbers for proper maze structure.
+// The same seed always produces the same maze, on every platform
+func NewMazeGenerator(width, height int, seed int64) *MazeGenerator {
 	// Ensure odd dimensions for proper maze structure
 	if width%2 == 0 {
 		width++
@@ -37,28 +67,56 @@ func NewMazeGenerator(width, height int) *MazeGenerator {
 	}
 
 	mg := &MazeGenerator{
-		width:  width,
-		height: height,
-		maze:   make([][]rune, height),
-		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		tileWidth:     width,
+		tileHeight:    height,
+		corridorWidth: 1,
+		wallThickness: 1,
+		rng:           rand.New(rand.NewSource(seed)),
+		algorithm:     RecursiveBacktracker{},
 	}
+	mg.resize(width, height)
+	return mg
+}
 
-	// Initialize maze grid
+// resize sets the size of the logical grid, filling it with walls
+func (mg *MazeGenerator) resize(width, height int) {
+	mg.width = width
+	mg.height = height
+	mg.maze = make([][]byte, height)
 	for y := 0; y < height; y++ {
-		mg.maze[y] = make([]rune, width)
+		mg.maze[y] = make([]byte, width)
 		for x := 0; x < width; x++ {
 			mg.maze[y][x] = '#' // Start with all walls
 		}
 	}
+}
 
-	return mg
+// SetCorridorSize changes the width of the corridors and the thickness of the
+// walls between them, in tiles. As many maze cells as fit in the requested size
+// are used, so the maze may come out slightly smaller
+func (mg *MazeGenerator) SetCorridorSize(corridorWidth, wallThickness int) {
+	mg.corridorWidth = max(1, corridorWidth)
+	mg.wallThickness = max(1, wallThickness)
+	cell := mg.corridorWidth + mg.wallThickness
+	cellsWide := max(1, (mg.tileWidth-mg.wallThickness)/cell)
+	cellsHigh := max(1, (mg.tileHeight-mg.wallThickness)/cell)
+	mg.resize(cellsWide*2+1, cellsHigh*2+1)
+}
+
+// SetAlgorithm changes the algorithm used to carve the maze
+func (mg *MazeGenerator) SetAlgorithm(algorithm MazeAlgorithm) {
+	mg.algorithm = algorithm
 }
 
-// GenerateMaze creates a maze using recursive backtracking algorithm
+// GenerateMaze creates a maze using the generator's algorithm (recursive backtracking by default)
 func (mg *MazeGenerator) GenerateMaze() []string {
-	// Start from position (1, 1) - first open cell
-	mg.maze[1][1] = '.'
-	mg.carvePassages(1, 1)
+	return mg.Expand(mg.carveMaze())
+}
+
+// carveMaze carves the logical grid, returning it without expanding the corridors
+func (mg *MazeGenerator) carveMaze() []string {
+	mg.algorithm.Carve(mg)
+	mg.stampVaults()
 
 	// Ensure border is all walls
 	mg.ensureBorder()
@@ -72,25 +130,76 @@ func (mg *MazeGenerator) GenerateMaze() []string {
 	return result
 }
 
-// carvePassages uses recursive backtracking to carve maze passages
+// Expand scales a maze carved on the logical grid up to the generator's
+// corridor width and wall thickness
+func (mg *MazeGenerator) Expand(maze []string) []string {
+	if (mg.corridorWidth == 1 && mg.wallThickness == 1) || len(maze) == 0 {
+		return maze
+	}
+
+	lastY, heightY := mg.expandSpan(len(maze) - 1)
+	lastX, widthX := mg.expandSpan(len(maze[0]) - 1)
+	result := make([]string, lastY+heightY)
+	row := make([]byte, lastX+widthX)
+	for y, line := range maze {
+		for x := range line {
+			start, size := mg.expandSpan(x)
+			for i := 0; i < size; i++ {
+				row[start+i] = line[x]
+			}
+		}
+		start, size := mg.expandSpan(y)
+		for i := 0; i < size; i++ {
+			result[start+i] = string(row)
+		}
+	}
+	return result
+}
+
+// expandSpan returns the first tile of a logical row or column once expanded, and how many tiles it covers.
+// Even rows and columns are walls, and odd ones are corridors
+func (mg *MazeGenerator) expandSpan(i int) (start, size int) {
+	start = i/2*(mg.corridorWidth+mg.wallThickness) + i%2*mg.wallThickness
+	if i%2 == 0 {
+		return start, mg.wallThickness
+	}
+	return start, mg.corridorWidth
+}
+
+// carveFrame is a cell being carved from, and the directions still to try
+type carveFrame struct {
+	x, y  int32
+	order [4]uint8 // Indexes into directions, in the order to try them
+	next  uint8    // How many directions have been tried so far
+}
+
+// carvePassages uses backtracking to carve maze passages. It keeps an explicit
+// stack rather than recursing, so very large mazes can't overflow the call stack
 func (mg *MazeGenerator) carvePassages(x, y int) {
-	// Randomize direction order
-	dirs := make([]Direction, len(directions))
-	copy(dirs, directions)
-	mg.shuffle(dirs)
+	stack := []carveFrame{{x: int32(x), y: int32(y), order: mg.shuffledDirections()}}
 
-	for _, dir := range dirs {
-		nx := x + dir.dx
-		ny := y + dir.dy
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if int(top.next) == len(directions) {
+			// Dead end - backtrack
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		dir := directions[top.order[top.next]]
+		top.next++
+
+		cx, cy := int(top.x), int(top.y)
+		nx := cx + dir.dx
+		ny := cy + dir.dy
 
 		// Check if the new position is valid and unvisited
 		if mg.isValidCell(nx, ny) && mg.maze[ny][nx] == '#' {
 			// Carve the wall between current and new cell
-			mg.maze[y+dir.dy/2][x+dir.dx/2] = '.'
+			mg.maze[cy+dir.dy/2][cx+dir.dx/2] = '.'
 			// Mark new cell as passage
 			mg.maze[ny][nx] = '.'
-			// Recursively carve from new cell
-			mg.carvePassages(nx, ny)
+			// Continue carving from new cell
+			stack = append(stack, carveFrame{x: int32(nx), y: int32(ny), order: mg.shuffledDirections()})
 		}
 	}
 }
@@ -100,6 +209,38 @@ func (mg *MazeGenerator) isValidCell(x, y int) bool {
 	return x > 0 && x < mg.width-1 && y > 0 && y < mg.height-1 && x%2 == 1 && y%2 == 1
 }
 
+// cellsWide returns the number of maze cells (odd grid positions) horizontally
+func (mg *MazeGenerator) cellsWide() int {
+	return (mg.width - 1) / 2
+}
+
+// cellsHigh returns the number of maze cells (odd grid positions) vertically
+func (mg *MazeGenerator) cellsHigh() int {
+	return (mg.height - 1) / 2
+}
+
+// inCells checks if maze cell coordinates are within the maze
+func (mg *MazeGenerator) inCells(cx, cy int) bool {
+	return cx >= 0 && cx < mg.cellsWide() && cy >= 0 && cy < mg.cellsHigh()
+}
+
+// openCell marks a maze cell as passage
+func (mg *MazeGenerator) openCell(cx, cy int) {
+	mg.maze[2*cy+1][2*cx+1] = '.'
+}
+
+// cellOpen checks if a maze cell has been carved yet
+func (mg *MazeGenerator) cellOpen(cx, cy int) bool {
+	return mg.maze[2*cy+1][2*cx+1] != '#'
+}
+
+// joinCells carves a passage between two adjacent maze cells
+func (mg *MazeGenerator) joinCells(ax, ay, bx, by int) {
+	mg.openCell(ax, ay)
+	mg.openCell(bx, by)
+	mg.maze[ay+by+1][ax+bx+1] = '.'
+}
+
 // ensureBorder makes sure all border cells are walls
 func (mg *MazeGenerator) ensureBorder() {
 	for x := 0; x < mg.width; x++ {
@@ -112,46 +253,308 @@ func (mg *MazeGenerator) ensureBorder() {
 	}
 }
 
-// shuffle randomizes the order of directions for random maze generation
-func (mg *MazeGenerator) shuffle(dirs []Direction) {
-	for i := len(dirs) - 1; i > 0; i-- {
+// shuffledDirections returns the indexes of directions in a random order for maze generation
+func (mg *MazeGenerator) shuffledDirections() [4]uint8 {
+	order := [4]uint8{0, 1, 2, 3}
+	for i := len(order) - 1; i > 0; i-- {
 		j := mg.rng.Intn(i + 1)
-		dirs[i], dirs[j] = dirs[j], dirs[i]
+		order[i], order[j] = order[j], order[i]
 	}
+	return order
 }
 
-// AddSpecialTiles adds special tiles to the maze (speed tiles, etc.)
-func (mg *MazeGenerator) AddSpecialTiles(maze []string, density float64) []string {
-	if density <= 0 || density > 1 {
-		return maze
+// AddHoles turns a fraction of the floor tiles into holes without blocking the
+// solution. A marble of the given radius following the solution keeps at least
+// clearance pixels between its edge and every hole
+func (mg *MazeGenerator) AddHoles(result *MazeResult, density, clearance, marbleRadius float64) {
+	if density <= 0 || len(result.Solution) == 0 {
+		return
 	}
 
-	result := make([]string, len(maze))
-	specialTiles := []rune{'<', '>', '(', ')'}
+	// Levels may already have holes of their own, so avoid those too where there's room
+	if route := safeRoute(result, clearance+marbleRadius); route != nil {
+		result.Solution = route
+	}
 
+	near := nearRoute(result.Lines, result.Solution, clearance+marbleRadius)
+	for y, row := range result.Lines {
+		tiles := []byte(row)
+		for x, tile := range tiles {
+			if tile == '.' && !near[y][x] && !mg.inExpandedVault(image.Pt(x, y)) && mg.rng.Float64() < density {
+				tiles[x] = 'O'
+			}
+		}
+		result.Lines[y] = string(tiles)
+	}
+
+	// Holes can cut off other parts of the maze, but the solution is still the shortest route
+	result.Distance = DistanceField(result.Lines, result.Start)
+}
+
+// safeRoute finds the shortest route from start to goal whose tile centres all
+// stay at least reach pixels from the holes already in the level, or nil if there isn't one
+func safeRoute(result *MazeResult, reach float64) []image.Point {
+	blocked := make([][]byte, len(result.Lines))
+	for y, line := range result.Lines {
+		blocked[y] = []byte(line)
+	}
+
+	span := int(math.Ceil(reach / tileSize))
+	for hy, line := range result.Lines {
+		for hx := range line {
+			if line[hx] != 'O' {
+				continue
+			}
+			for y := max(0, hy-span); y <= min(len(blocked)-1, hy+span); y++ {
+				for x := max(0, hx-span); x <= min(len(blocked[y])-1, hx+span); x++ {
+					dx := math.Max(0, math.Abs(float64(x-hx))*tileSize-tileSize/2)
+					dy := math.Max(0, math.Abs(float64(y-hy))*tileSize-tileSize/2)
+					if math.Hypot(dx, dy) < reach && blocked[y][x] != 'S' && blocked[y][x] != 'G' {
+						blocked[y][x] = '#'
+					}
+				}
+			}
+		}
+	}
+
+	lines := make([]string, len(blocked))
+	for y, row := range blocked {
+		lines[y] = string(row)
+	}
+	return solutionPath(DistanceField(lines, result.Start), result.Goal, lines)
+}
+
+// nearRoute marks the tiles which come within reach pixels of a marble's centre
+// as it rolls from tile centre to tile centre along a route
+func nearRoute(lines []string, route []image.Point, reach float64) [][]bool {
+	near := make([][]bool, len(lines))
+	for y, line := range lines {
+		near[y] = make([]bool, len(line))
+	}
+
+	span := int(math.Ceil(reach/tileSize)) + 1
+	for i, a := range route {
+		b := route[min(i+1, len(route)-1)]
+		// The segment between the two centres, in pixels
+		segMinX, segMaxX := (float64(min(a.X, b.X))+0.5)*tileSize, (float64(max(a.X, b.X))+0.5)*tileSize
+		segMinY, segMaxY := (float64(min(a.Y, b.Y))+0.5)*tileSize, (float64(max(a.Y, b.Y))+0.5)*tileSize
+
+		for y := min(a.Y, b.Y) - span; y <= max(a.Y, b.Y)+span; y++ {
+			for x := min(a.X, b.X) - span; x <= max(a.X, b.X)+span; x++ {
+				if y < 0 || y >= len(near) || x < 0 || x >= len(near[y]) {
+					continue
+				}
+				dx := math.Max(0, math.Max(float64(x)*tileSize-segMaxX, segMinX-float64(x+1)*tileSize))
+				dy := math.Max(0, math.Max(float64(y)*tileSize-segMaxY, segMinY-float64(y+1)*tileSize))
+				if math.Hypot(dx, dy) < reach {
+					near[y][x] = true
+				}
+			}
+		}
+	}
+	return near
+}
+
+// Braid turns a perfect maze into one with loops. A fraction of the dead ends are
+// removed by knocking through to a neighbouring cell, preferring neighbours that
+// are dead ends themselves, then remaining walls between cells are knocked out
+// at random with the given loop density
+func (mg *MazeGenerator) Braid(maze []string, deadEndFraction, loopDensity float64) []string {
+	grid := make([][]byte, len(maze))
 	for y, row := range maze {
-		runes := []rune(row)
-		for x, cell := range runes {
-			if cell == '.' && mg.rng.Float64() < density {
-				// Replace with random special tile
-				runes[x] = specialTiles[mg.rng.Intn(len(specialTiles))]
+		grid[y] = []byte(row)
+	}
+	height := len(grid)
+	width := 0
+	if height > 0 {
+		width = len(grid[0])
+	}
+	isCell := func(x, y int) bool {
+		return x > 0 && x < width-1 && y > 0 && y < height-1 && x%2 == 1 && y%2 == 1 && !mg.inVault(x, y)
+	}
+	openSides := func(x, y int) int {
+		count := 0
+		for _, dir := range directions {
+			if grid[y+dir.dy/2][x+dir.dx/2] != '#' {
+				count++
+			}
+		}
+		return count
+	}
+
+	if deadEndFraction > 0 {
+		// Find the dead ends, and visit them in a random order
+		var deadEnds []image.Point
+		for y := 1; y < height-1; y += 2 {
+			for x := 1; x < width-1; x += 2 {
+				if isCell(x, y) && grid[y][x] != '#' && openSides(x, y) == 1 {
+					deadEnds = append(deadEnds, image.Pt(x, y))
+				}
+			}
+		}
+		for i := len(deadEnds) - 1; i > 0; i-- {
+			j := mg.rng.Intn(i + 1)
+			deadEnds[i], deadEnds[j] = deadEnds[j], deadEnds[i]
+		}
+
+		for _, cell := range deadEnds {
+			x, y := cell.X, cell.Y
+			// Earlier braiding may already have fixed this one
+			if openSides(x, y) != 1 || mg.rng.Float64() >= deadEndFraction {
+				continue
+			}
+			var walls, deadEndWalls []Direction
+			for _, dir := range directions {
+				nx, ny := x+dir.dx, y+dir.dy
+				if !isCell(nx, ny) || grid[y+dir.dy/2][x+dir.dx/2] != '#' {
+					continue
+				}
+				walls = append(walls, dir)
+				if openSides(nx, ny) == 1 {
+					deadEndWalls = append(deadEndWalls, dir)
+				}
+			}
+			if len(deadEndWalls) > 0 {
+				walls = deadEndWalls
+			}
+			if len(walls) > 0 {
+				dir := walls[mg.rng.Intn(len(walls))]
+				grid[y+dir.dy/2][x+dir.dx/2] = '.'
+			}
+		}
+	}
+
+	if loopDensity > 0 {
+		// Walls between two cells sit on an odd row and even column, or vice versa
+		for y := 1; y < height-1; y++ {
+			for x := 1 + y%2; x < width-1; x += 2 {
+				if grid[y][x] == '#' && !mg.inVault(x, y) && mg.rng.Float64() < loopDensity {
+					grid[y][x] = '.'
+				}
 			}
 		}
-		result[y] = string(runes)
 	}
 
+	result := make([]string, height)
+	for y, row := range grid {
+		result[y] = string(row)
+	}
 	return result
 }
 
 // CreateSimpleMaze creates a basic maze without complex algorithms (for smaller mazes)
-func CreateSimpleMaze(width, height int) []string {
-	mg := NewMazeGenerator(width, height)
+func CreateSimpleMaze(width, height int, seed int64) []string {
+	mg := NewMazeGenerator(width, height, seed)
 	return mg.GenerateMaze()
 }
 
-// CreateMazeWithSpecialTiles creates a maze and adds special speed tiles
-func CreateMazeWithSpecialTiles(width, height int, specialTileDensity float64) []string {
-	mg := NewMazeGenerator(width, height)
-	maze := mg.GenerateMaze()
-	return mg.AddSpecialTiles(maze, specialTileDensity)
+// CreateMazeWithSpecialTiles creates a maze with the start and goal as far apart
+// as possible, and adds special speed tiles
+func CreateMazeWithSpecialTiles(opts MazeOptions) *MazeResult {
+	return createMaze(opts, nil)
+}
+
+// CreateMultiFloorMaze creates a level of opts.Floors floors, from the bottom up,
+// each a maze of its own using the next seed. Every floor but the top has a ramp
+// up in place of its goal, arriving at the same spot on the floor above, where
+// there's a ramp back down. The top floor has the real goal
+func CreateMultiFloorMaze(opts MazeOptions) []*MazeResult {
+	floors := make([]*MazeResult, max(1, opts.Floors))
+	for i := range floors {
+		floorOpts := opts
+		floorOpts.Seed = opts.Seed + int64(i)
+		if i == 0 {
+			floors[i] = createMaze(floorOpts, nil)
+			continue
+		}
+		arrival := floors[i-1].Goal
+		floors[i] = createMaze(floorOpts, &arrival)
+		floors[i-1].setTile(arrival, '^')
+		floors[i].setTile(arrival, 'v')
+	}
+	return floors
+}
+
+// createMaze creates a maze with special tiles. The start is as far from the
+// goal as possible, unless it's given
+func createMaze(opts MazeOptions, start *image.Point) *MazeResult {
+	mg := NewMazeGenerator(opts.Width, opts.Height, opts.Seed)
+	var maze []string
+	if generate := levelGeneratorByName(opts.Algorithm); generate != nil {
+		maze = generate(opts)
+	} else {
+		mg.SetAlgorithm(MazeAlgorithmByName(opts.Algorithm))
+		mg.SetCorridorSize(opts.CorridorWidth, opts.WallThickness)
+		mg.ReservePrefabs(builtinPrefabs(), opts.Prefabs)
+		maze = mg.carveMaze()
+		maze = mg.Expand(mg.Braid(maze, opts.BraidFraction, opts.LoopDensity))
+	}
+	var result *MazeResult
+	if start != nil {
+		result = PlaceGoalFrom(openTile(maze, *start), *start)
+	} else {
+		result = PlaceStartAndGoal(maze)
+	}
+	mg.AddHoles(result, opts.HoleDensity, opts.HoleClearance, opts.MarbleRadius)
+	mg.PlaceSpecialTiles(result, opts.SpecialTileDensity, opts.TileWeights)
+	result.Options = opts
+	return result
+}
+
+// levelGenerators build whole levels in their own style rather than carving a
+// maze, so braiding doesn't apply to them. Listed in menu order, after the maze algorithms
+var levelGenerators = []struct {
+	Name     string
+	Generate func(opts MazeOptions) []string
+}{
+	{"cave", func(opts MazeOptions) []string {
+		return NewCaveGenerator(opts.Width, opts.Height, opts.Seed).Generate()
+	}},
+	{"dungeon", func(opts MazeOptions) []string {
+		dg := NewDungeonGenerator(opts.Width, opts.Height, opts.Seed)
+		if opts.CorridorWidth > 0 {
+			dg.CorridorWidth = opts.CorridorWidth
+		}
+		return dg.Generate()
+	}},
+	{"wfc", func(opts MazeOptions) []string {
+		if level := NewWFCGenerator(opts.Width, opts.Height, opts.Seed, sampleMaps()).Generate(); level != nil {
+			return level
+		}
+		log.Printf("Failed to generate a level from the sample maps, falling back to a maze")
+		return NewMazeGenerator(opts.Width, opts.Height, opts.Seed).GenerateMaze()
+	}},
+}
+
+// levelGeneratorByName returns the named level generator, or nil if there isn't one
+func levelGeneratorByName(name string) func(opts MazeOptions) []string {
+	for _, generator := range levelGenerators {
+		if generator.Name == name {
+			return generator.Generate
+		}
+	}
+	return nil
+}
+
+// GeneratorNames lists every maze algorithm and level generator, in menu order
+func GeneratorNames() []string {
+	var names []string
+	for _, algorithm := range mazeAlgorithms {
+		names = append(names, algorithm.Name())
+	}
+	for _, generator := range levelGenerators {
+		names = append(names, generator.Name)
+	}
+	return names
+}
+
+// sampleMapCache holds the sample maps once they've been loaded
+var sampleMapCache [][]string
+
+// sampleMaps returns the hand-made example levels that the WFC generator learns from
+func sampleMaps() [][]string {
+	if sampleMapCache == nil {
+		sampleMapCache = LoadSampleMaps(assetsFS, "assets/levels")
+	}
+	return sampleMapCache
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestMultiFloorWeaveSolvable(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		floors := CreateMultiFloorMaze(MazeOptions{
+			Width:         41,
+			Height:        25,
+			Seed:          seed,
+			Algorithm:     WeaveAlgorithm{}.Name(),
+			BraidFraction: 0.3,
+			LoopDensity:   0.05,
+			Floors:        3,
+			MarbleRadius:  15,
+		})
+		for i, floor := range floors {
+			if len(floor.Solution) == 0 {
+				t.Errorf("seed %d floor %d: no route from start to goal", seed, i+1)
+			}
+		}
+	}
+}
+
+func TestArrivalOnBridge(t *testing.T) {
+	opts := MazeOptions{
+		Width:         41,
+		Height:        25,
+		Seed:          4,
+		Algorithm:     WeaveAlgorithm{}.Name(),
+		BraidFraction: 0.3,
+		LoopDensity:   0.05,
+		MarbleRadius:  15,
+	}
+	start := image.Pt(35, 21)
+	floor := createMaze(opts, &start)
+	floor.setTile(start, 'v')
+	if len(floor.Solution) == 0 {
+		t.Fatal("no route from the ramp to the goal")
+	}
+	if got := solutionPath(DistanceField(floor.Lines, start), floor.Goal, floor.Lines); len(got) == 0 {
+		t.Error("no route from the ramp to the goal once it's marked")
+	}
+}
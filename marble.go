@@ -15,6 +15,7 @@ type Marble struct {
 	Radius   float64 // Radius of the marble
 	Color    color.Color
 	Friction float64 // Friction coefficient (0-1, where 1 = no friction)
+	Level    int     // Height above the ground: 1 on a bridge deck, 0 anywhere else
 }
 
 // NewMarble creates a new marble at the specified position
@@ -80,15 +81,18 @@ func (m *Marble) GetVelocity() (float64, float64) {
 	return m.VX, m.VY
 }
 
-// Draw renders the marble to the screen
-func (m *Marble) Draw(screen *ebiten.Image) {
+// Draw renders the marble to the screen as seen through the camera
+func (m *Marble) Draw(screen *ebiten.Image, camera *Camera) {
+	screenX, screenY := camera.WorldToScreen(m.X, m.Y)
+	radius := m.Radius * camera.Zoom
+
 	// Draw the marble as a filled circle
-	vector.DrawFilledCircle(screen, float32(m.X), float32(m.Y), float32(m.Radius), m.Color, true)
+	vector.DrawFilledCircle(screen, float32(screenX), float32(screenY), float32(radius), m.Color, true)
 
 	// Draw a subtle highlight to make it look more 3D
 	highlightColor := color.RGBA{255, 255, 255, 100}
-	highlightX := float32(m.X - m.Radius*0.3)
-	highlightY := float32(m.Y - m.Radius*0.3)
-	highlightRadius := float32(m.Radius * 0.3)
+	highlightX := float32(screenX - radius*0.3)
+	highlightY := float32(screenY - radius*0.3)
+	highlightRadius := float32(radius * 0.3)
 	vector.DrawFilledCircle(screen, highlightX, highlightY, highlightRadius, highlightColor, true)
 }
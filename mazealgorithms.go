@@ -0,0 +1,383 @@
+package main
+
+import (
+	"image"
+)
+
+// MazeAlgorithm carves passages into a MazeGenerator's grid of walls.
+//
+// Algorithms work on maze cells - the odd tile positions of the grid - and use
+// the MazeGenerator's cell helpers and random number generator, so that the
+// same seed always produces the same maze
+type MazeAlgorithm interface {
+	// Name is the short name used to select the algorithm
+	Name() string
+	// Carve turns the all-wall grid into a perfect maze
+	Carve(mg *MazeGenerator)
+}
+
+// mazeAlgorithms lists all the available algorithms, in menu order
+var mazeAlgorithms = []MazeAlgorithm{
+	RecursiveBacktracker{},
+	PrimAlgorithm{},
+	KruskalAlgorithm{},
+	WeaveAlgorithm{Crossings: 0.3},
+	WilsonAlgorithm{},
+	EllerAlgorithm{},
+	HuntAndKillAlgorithm{},
+	BinaryTreeAlgorithm{},
+}
+
+// MazeAlgorithmByName returns the named algorithm, or the recursive backtracker if it isn't known
+func MazeAlgorithmByName(name string) MazeAlgorithm {
+	for _, algorithm := range mazeAlgorithms {
+		if algorithm.Name() == name {
+			return algorithm
+		}
+	}
+	return RecursiveBacktracker{}
+}
+
+// cellSteps are the offsets to neighbouring maze cells: up, right, down, left
+var cellSteps = [4]image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+
+// RecursiveBacktracker carves long, winding corridors with few branches
+type RecursiveBacktracker struct{}
+
+func (RecursiveBacktracker) Name() string { return "backtracker" }
+
+func (RecursiveBacktracker) Carve(mg *MazeGenerator) {
+	// Start from position (1, 1) - first open cell
+	mg.maze[1][1] = '.'
+	mg.carvePassages(1, 1)
+}
+
+// PrimAlgorithm grows the maze outwards from a single cell, giving lots of
+// short dead ends radiating from the start
+type PrimAlgorithm struct{}
+
+func (PrimAlgorithm) Name() string { return "prim" }
+
+func (PrimAlgorithm) Carve(mg *MazeGenerator) {
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	inFrontier := make([]bool, w*h)
+	var frontier []image.Point
+
+	// add puts a cell in the maze, and its neighbours in the frontier
+	add := func(c image.Point) {
+		mg.openCell(c.X, c.Y)
+		for _, step := range cellSteps {
+			n := c.Add(step)
+			if mg.inCells(n.X, n.Y) && !mg.cellOpen(n.X, n.Y) && !inFrontier[n.Y*w+n.X] {
+				inFrontier[n.Y*w+n.X] = true
+				frontier = append(frontier, n)
+			}
+		}
+	}
+
+	add(image.Pt(mg.rng.Intn(w), mg.rng.Intn(h)))
+	for len(frontier) > 0 {
+		i := mg.rng.Intn(len(frontier))
+		cell := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		// Join to a random neighbour that's already in the maze
+		var inMaze []image.Point
+		for _, step := range cellSteps {
+			n := cell.Add(step)
+			if mg.inCells(n.X, n.Y) && mg.cellOpen(n.X, n.Y) {
+				inMaze = append(inMaze, n)
+			}
+		}
+		n := inMaze[mg.rng.Intn(len(inMaze))]
+		mg.joinCells(cell.X, cell.Y, n.X, n.Y)
+		add(cell)
+	}
+}
+
+// KruskalAlgorithm joins randomly chosen walls between unconnected regions,
+// giving an even spread of short corridors
+type KruskalAlgorithm struct{}
+
+func (KruskalAlgorithm) Name() string { return "kruskal" }
+
+func (KruskalAlgorithm) Carve(mg *MazeGenerator) {
+	joinAtRandom(mg, newDisjointSet(mg.cellsWide()*mg.cellsHigh()), nil)
+}
+
+// joinAtRandom goes through every wall between two cells in a random order,
+// knocking it down if the cells aren't already connected. Cells marked as
+// taken are left alone
+func joinAtRandom(mg *MazeGenerator, sets disjointSet, taken []bool) {
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	free := func(x, y int) bool { return taken == nil || !taken[y*w+x] }
+
+	// Every wall between two cells, as the first cell and the direction to the second
+	type edge struct {
+		cell image.Point
+		step image.Point
+	}
+	var edges []edge
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !free(x, y) {
+				continue
+			}
+			if !mg.cellOpen(x, y) {
+				mg.openCell(x, y)
+			}
+			if x+1 < w && free(x+1, y) {
+				edges = append(edges, edge{image.Pt(x, y), cellSteps[1]})
+			}
+			if y+1 < h && free(x, y+1) {
+				edges = append(edges, edge{image.Pt(x, y), cellSteps[2]})
+			}
+		}
+	}
+	for i := len(edges) - 1; i > 0; i-- {
+		j := mg.rng.Intn(i + 1)
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+
+	for _, e := range edges {
+		n := e.cell.Add(e.step)
+		if sets.union(e.cell.Y*w+e.cell.X, n.Y*w+n.X) {
+			mg.joinCells(e.cell.X, e.cell.Y, n.X, n.Y)
+		}
+	}
+}
+
+// WeaveAlgorithm makes mazes where corridors cross over and under each other on
+// bridges. Crossings are laid out first, then the rest is joined up like
+// Kruskal's algorithm, so the result is still a perfect maze
+type WeaveAlgorithm struct {
+	Crossings float64 // Chance of each cell becoming a crossing, where it can be
+}
+
+func (WeaveAlgorithm) Name() string { return "weave" }
+
+func (a WeaveAlgorithm) Carve(mg *MazeGenerator) {
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	sets := newDisjointSet(w * h)
+	crossings := make([]bool, w*h)
+	id := func(p image.Point) int { return p.Y*w + p.X }
+
+	// Crossings need a cell on every side, and can't be next to each other as
+	// the corridors leading to a bridge have to be straight
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			if mg.rng.Float64() >= a.Crossings || crossings[id(image.Pt(x-1, y))] || crossings[id(image.Pt(x, y-1))] {
+				continue
+			}
+			cell := image.Pt(x, y)
+			north, east := sets.find(id(cell.Add(cellSteps[0]))), sets.find(id(cell.Add(cellSteps[1])))
+			south, west := sets.find(id(cell.Add(cellSteps[2]))), sets.find(id(cell.Add(cellSteps[3])))
+			if north == south || east == west || (north == east && south == west) || (north == west && south == east) {
+				continue // Either corridor would close a loop
+			}
+			crossings[id(cell)] = true
+			sets.union(north, south)
+			sets.union(east, west)
+			for _, step := range cellSteps {
+				n := cell.Add(step)
+				mg.joinCells(x, y, n.X, n.Y)
+			}
+
+			// Either corridor can go over the top
+			deck := byte('|')
+			if mg.rng.Intn(2) == 0 {
+				deck = '-'
+			}
+			mg.maze[2*y+1][2*x+1] = deck
+		}
+	}
+
+	joinAtRandom(mg, sets, crossings)
+}
+
+// disjointSet is a union-find structure over integer ids
+type disjointSet []int
+
+func newDisjointSet(size int) disjointSet {
+	s := make(disjointSet, size)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// find returns the representative id of the set containing i
+func (s disjointSet) find(i int) int {
+	for s[i] != i {
+		s[i] = s[s[i]] // Path halving
+		i = s[i]
+	}
+	return i
+}
+
+// union merges the sets containing a and b, returning false if they were already the same set
+func (s disjointSet) union(a, b int) bool {
+	ra, rb := s.find(a), s.find(b)
+	if ra == rb {
+		return false
+	}
+	s[rb] = ra
+	return true
+}
+
+// WilsonAlgorithm uses loop-erased random walks, giving a completely unbiased maze
+type WilsonAlgorithm struct{}
+
+func (WilsonAlgorithm) Name() string { return "wilson" }
+
+func (WilsonAlgorithm) Carve(mg *MazeGenerator) {
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	next := make([]int, w*h) // Direction last taken out of each cell during a walk
+
+	// Visit cells in a random order, walking from each one not yet in the maze
+	order := make([]int, w*h)
+	for i := range order {
+		order[i] = i
+	}
+	for i := len(order) - 1; i > 0; i-- {
+		j := mg.rng.Intn(i + 1)
+		order[i], order[j] = order[j], order[i]
+	}
+
+	inMaze := make([]bool, w*h)
+	inMaze[order[0]] = true
+	mg.openCell(order[0]%w, order[0]/w)
+	for _, start := range order[1:] {
+		if inMaze[start] {
+			continue
+		}
+
+		// Random walk until we hit the maze. Revisiting a cell overwrites its
+		// direction, which erases any loop
+		cell := image.Pt(start%w, start/w)
+		for !inMaze[cell.Y*w+cell.X] {
+			dir := mg.rng.Intn(len(cellSteps))
+			n := cell.Add(cellSteps[dir])
+			if !mg.inCells(n.X, n.Y) {
+				continue
+			}
+			next[cell.Y*w+cell.X] = dir
+			cell = n
+		}
+
+		// Follow the recorded directions, adding the path to the maze
+		cell = image.Pt(start%w, start/w)
+		for !inMaze[cell.Y*w+cell.X] {
+			inMaze[cell.Y*w+cell.X] = true
+			n := cell.Add(cellSteps[next[cell.Y*w+cell.X]])
+			mg.joinCells(cell.X, cell.Y, n.X, n.Y)
+			cell = n
+		}
+	}
+}
+
+// EllerAlgorithm builds the maze one row at a time, giving long horizontal runs.
+// See MazeStream
+type EllerAlgorithm struct{}
+
+func (EllerAlgorithm) Name() string { return "eller" }
+
+func (EllerAlgorithm) Carve(mg *MazeGenerator) {
+	stream := newMazeStream(mg.cellsWide(), mg.rng)
+	h := mg.cellsHigh()
+	for y := 0; y < h; y++ {
+		cellRow, wallRow := stream.NextRow(y == h-1)
+		copy(mg.maze[2*y+1], cellRow)
+		copy(mg.maze[2*y+2], wallRow)
+	}
+}
+
+// HuntAndKillAlgorithm walks randomly until stuck, then hunts for an unvisited
+// cell next to the maze. It gives long corridors like the backtracker, but
+// without the deep recursion
+type HuntAndKillAlgorithm struct{}
+
+func (HuntAndKillAlgorithm) Name() string { return "huntandkill" }
+
+func (HuntAndKillAlgorithm) Carve(mg *MazeGenerator) {
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	cell := image.Pt(mg.rng.Intn(w), mg.rng.Intn(h))
+	mg.openCell(cell.X, cell.Y)
+	huntRow := 0 // Rows above this are known to be completely visited
+
+	for {
+		// Kill: walk to random unvisited neighbours until there aren't any
+		if n, ok := mg.randomCellNeighbour(cell, false); ok {
+			mg.joinCells(cell.X, cell.Y, n.X, n.Y)
+			cell = n
+			continue
+		}
+
+		// Hunt: find an unvisited cell next to the maze and join it on
+		found := false
+		for y := huntRow; y < h && !found; y++ {
+			rowDone := true
+			for x := 0; x < w; x++ {
+				if mg.cellOpen(x, y) {
+					continue
+				}
+				rowDone = false
+				if n, ok := mg.randomCellNeighbour(image.Pt(x, y), true); ok {
+					mg.joinCells(x, y, n.X, n.Y)
+					cell = image.Pt(x, y)
+					found = true
+					break
+				}
+			}
+			if rowDone && y == huntRow {
+				huntRow++
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// randomCellNeighbour picks a random neighbouring cell which is open (or not open) already
+func (mg *MazeGenerator) randomCellNeighbour(cell image.Point, open bool) (image.Point, bool) {
+	var options [4]image.Point
+	count := 0
+	for _, step := range cellSteps {
+		n := cell.Add(step)
+		if mg.inCells(n.X, n.Y) && mg.cellOpen(n.X, n.Y) == open {
+			options[count] = n
+			count++
+		}
+	}
+	if count == 0 {
+		return image.Point{}, false
+	}
+	return options[mg.rng.Intn(count)], true
+}
+
+// BinaryTreeAlgorithm joins every cell either up or left. It's very fast, but
+// leaves long open corridors along the top and left edges
+type BinaryTreeAlgorithm struct{}
+
+func (BinaryTreeAlgorithm) Name() string { return "binarytree" }
+
+func (BinaryTreeAlgorithm) Carve(mg *MazeGenerator) {
+	for y := 0; y < mg.cellsHigh(); y++ {
+		for x := 0; x < mg.cellsWide(); x++ {
+			mg.openCell(x, y)
+			switch {
+			case x == 0 && y == 0:
+			case x == 0:
+				mg.joinCells(x, y, x, y-1)
+			case y == 0:
+				mg.joinCells(x, y, x-1, y)
+			case mg.rng.Intn(2) == 0:
+				mg.joinCells(x, y, x, y-1)
+			default:
+				mg.joinCells(x, y, x-1, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"image"
+	"slices"
+)
+
+// MazeResult is a generated maze along with what's known about its layout
+type MazeResult struct {
+	Lines    []string      // ASCII rows, as consumed by NewGameMap
+	Start    image.Point   // Tile the marble starts on, marked 'S'
+	Goal     image.Point   // Tile the marble has to reach, marked 'G'
+	Distance [][]int       // Steps from Start to every tile, or -1 if it can't be reached
+	Solution []image.Point // Shortest safe route from Start to Goal, including both
+	Options  MazeOptions   // Options the maze was generated with
+}
+
+// asciiWalkable checks if a level character can be safely rolled over
+func asciiWalkable(char byte) bool {
+	def, ok := tileChars[rune(char)]
+	return !ok || (!def.solid && def.typ != TileHole)
+}
+
+// asciiBridge returns the direction a bridge character's deck runs in, or false
+// if it isn't a bridge
+func asciiBridge(char byte) (image.Point, bool) {
+	return bridgeDeck(tileChars[rune(char)].typ)
+}
+
+// DistanceField returns the number of steps from the given tile to every other
+// tile in an ASCII maze, moving horizontally and vertically. Routes go straight
+// over or under bridges, so a bridge has the distance of whichever way across
+// it is shorter. Tiles that can't be reached are -1
+func DistanceField(lines []string, from image.Point) [][]int {
+	dist := make([][]int, len(lines))
+	for y, line := range lines {
+		dist[y] = make([]int, len(line))
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	if from.Y < 0 || from.Y >= len(lines) || from.X < 0 || from.X >= len(lines[from.Y]) {
+		return dist
+	}
+
+	// Breadth-first search. Each visit remembers the step that got there, as
+	// routes can't turn on a bridge
+	type visit struct {
+		cell, step image.Point
+		dist       int
+	}
+	crossed := map[visit]bool{} // Bridges and the axis they've been crossed along
+	dist[from.Y][from.X] = 0
+	queue := []visit{{cell: from}}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		steps := cellSteps[:]
+		if _, onBridge := asciiBridge(lines[v.cell.Y][v.cell.X]); onBridge && v.step != (image.Point{}) {
+			steps = []image.Point{v.step}
+		}
+		for _, step := range steps {
+			n := v.cell.Add(step)
+			if n.Y < 0 || n.Y >= len(lines) || n.X < 0 || n.X >= len(lines[n.Y]) || !asciiWalkable(lines[n.Y][n.X]) {
+				continue
+			}
+			if _, isBridge := asciiBridge(lines[n.Y][n.X]); isBridge {
+				// A bridge can be crossed twice: once over and once under
+				axis := visit{cell: n, step: image.Pt(abs(step.X), abs(step.Y))}
+				if crossed[axis] {
+					continue
+				}
+				crossed[axis] = true
+				if dist[n.Y][n.X] < 0 {
+					dist[n.Y][n.X] = v.dist + 1
+				}
+			} else if dist[n.Y][n.X] >= 0 {
+				continue
+			} else {
+				dist[n.Y][n.X] = v.dist + 1
+			}
+			queue = append(queue, visit{n, step, v.dist + 1})
+		}
+	}
+	return dist
+}
+
+// farthestTile returns the reachable tile with the largest distance. Ties go to
+// the first one found, top to bottom
+func farthestTile(dist [][]int) image.Point {
+	best := image.Point{}
+	bestDist := -1
+	for y, row := range dist {
+		for x, d := range row {
+			if d > bestDist {
+				best = image.Pt(x, y)
+				bestDist = d
+			}
+		}
+	}
+	return best
+}
+
+// solutionPath walks back downhill through a distance field from the goal,
+// returning the route from the distance field's origin to the goal. If the
+// field came from an ASCII maze, its lines are needed to cross bridges straight
+func solutionPath(dist [][]int, goal image.Point, lines []string) []image.Point {
+	if dist[goal.Y][goal.X] < 0 {
+		return nil
+	}
+	path := make([]image.Point, dist[goal.Y][goal.X]+1)
+	distAt := func(p image.Point) int {
+		if p.Y < 0 || p.Y >= len(dist) || p.X < 0 || p.X >= len(dist[p.Y]) {
+			return -1
+		}
+		return dist[p.Y][p.X]
+	}
+	isBridge := func(p image.Point) bool {
+		if lines == nil || distAt(p) < 0 {
+			return false
+		}
+		_, ok := asciiBridge(lines[p.Y][p.X])
+		return ok
+	}
+
+	cell := goal
+	for i := len(path) - 1; i > 0; {
+		path[i] = cell
+		moved := false
+		for _, step := range cellSteps {
+			// Bridges are crossed in a straight line to the tile beyond them
+			n := cell.Add(step)
+			k := 1
+			for isBridge(n) {
+				n = n.Add(step)
+				k++
+			}
+			if k > i || distAt(n) != i-k || (k > 1 && isBridge(cell)) {
+				continue
+			}
+			for j := 1; j < k; j++ {
+				path[i-j] = cell.Add(step.Mul(j))
+			}
+			cell = n
+			i -= k
+			moved = true
+			break
+		}
+		if !moved {
+			return nil // The distance field doesn't lead back from here
+		}
+	}
+	path[0] = cell
+	return path
+}
+
+// PlaceStartAndGoal finds the longest shortest-path in a maze and puts the
+// start and goal at its two ends, marking them 'S' and 'G'. In a perfect maze
+// this is the longest possible route; with loops it's a close approximation
+func PlaceStartAndGoal(lines []string) *MazeResult {
+	result := &MazeResult{Lines: lines}
+
+	// Search from any open tile to find one end of the longest route...
+	from := image.Pt(-1, -1)
+	for y := 0; y < len(lines) && from.X < 0; y++ {
+		for x := 0; x < len(lines[y]); x++ {
+			if asciiWalkable(lines[y][x]) {
+				from = image.Pt(x, y)
+				break
+			}
+		}
+	}
+	if from.X < 0 {
+		result.Distance = DistanceField(lines, from)
+		return result // Nothing but walls
+	}
+
+	// ...then from there to find the other end
+	return PlaceGoalFrom(lines, farthestTile(DistanceField(lines, from)))
+}
+
+// PlaceGoalFrom puts the goal as far as possible from the given start, marking
+// them 'S' and 'G'
+func PlaceGoalFrom(lines []string, start image.Point) *MazeResult {
+	result := &MazeResult{Start: start}
+	result.Distance = DistanceField(lines, result.Start)
+	result.Goal = farthestTile(result.Distance)
+	result.Solution = solutionPath(result.Distance, result.Goal, lines)
+
+	result.Lines = make([]string, len(lines))
+	copy(result.Lines, lines)
+	result.setTile(result.Start, 'S')
+	result.setTile(result.Goal, 'G')
+	return result
+}
+
+// openTile makes sure the given tile of a maze can be rolled over, digging a
+// passage from it to the nearest open tile if it can't. A bridge is flattened
+// into a crossroads, so the tile can be rolled onto from every side, as it can
+// once a ramp is put there. The border is left intact
+func openTile(lines []string, p image.Point) []string {
+	if _, isBridge := asciiBridge(lines[p.Y][p.X]); isBridge {
+		lines = slices.Clone(lines)
+		lines[p.Y] = lines[p.Y][:p.X] + "." + lines[p.Y][p.X+1:]
+		return lines
+	}
+	if asciiWalkable(lines[p.Y][p.X]) {
+		return lines
+	}
+
+	// Breadth-first search through walls and all, inside the border
+	from := map[image.Point]image.Point{p: p}
+	queue := []image.Point{p}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		if asciiWalkable(lines[cell.Y][cell.X]) {
+			// Dig back to where the search started
+			grid := make([][]byte, len(lines))
+			for y, line := range lines {
+				grid[y] = []byte(line)
+			}
+			for cell = from[cell]; cell != p; cell = from[cell] {
+				grid[cell.Y][cell.X] = '.'
+			}
+			grid[p.Y][p.X] = '.'
+			result := make([]string, len(grid))
+			for y, row := range grid {
+				result[y] = string(row)
+			}
+			return result
+		}
+		for _, step := range cellSteps {
+			n := cell.Add(step)
+			if n.Y < 1 || n.Y >= len(lines)-1 || n.X < 1 || n.X >= len(lines[n.Y])-1 {
+				continue
+			}
+			if _, seen := from[n]; !seen {
+				from[n] = cell
+				queue = append(queue, n)
+			}
+		}
+	}
+	return lines // Nowhere to dig to
+}
+
+// setTile replaces a single character of the maze
+func (r *MazeResult) setTile(p image.Point, char byte) {
+	row := []byte(r.Lines[p.Y])
+	row[p.X] = char
+	r.Lines[p.Y] = string(row)
+}
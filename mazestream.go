@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+)
+
+// MazeStream generates a maze one row at a time using Eller's algorithm.
+// Only the current row is kept in memory, so mazes can be arbitrarily tall -
+// or endless - using memory proportional to the width alone
+type MazeStream struct {
+	cells  int        // Number of maze cells across
+	rng    *rand.Rand // Source of randomness
+	sets   []int      // Set of each cell in the current row, or -1 if it has none yet
+	joined disjointSet
+	used   []bool // Set ids in use by the current row
+	seen   []int  // Number of cells seen in each set so far, when choosing passages down
+	chosen []int  // Cell chosen to guarantee a passage down for each set
+}
+
+// NewMazeStream creates a stream of maze rows, width tiles wide. The width should
+// be odd for proper maze structure. The same seed always produces the same rows
+func NewMazeStream(width int, seed int64) *MazeStream {
+	return newMazeStream((width-1)/2, rand.New(rand.NewSource(seed)))
+}
+
+// newMazeStream creates a stream of maze rows which is cells maze cells wide
+func newMazeStream(cells int, rng *rand.Rand) *MazeStream {
+	s := &MazeStream{
+		cells:  cells,
+		rng:    rng,
+		sets:   make([]int, cells),
+		joined: newDisjointSet(cells),
+		used:   make([]bool, cells),
+		seen:   make([]int, cells),
+		chosen: make([]int, cells),
+	}
+	for x := range s.sets {
+		s.sets[x] = -1
+	}
+	return s
+}
+
+// Width returns the width of each row in tiles
+func (s *MazeStream) Width() int {
+	return s.cells*2 + 1
+}
+
+// NextRow generates the next row of maze cells. It returns the tile row holding
+// the cells, and the tile row below it with the passages down to the next row.
+// On the last row every remaining region is joined up, and the row below is solid wall
+func (s *MazeStream) NextRow(last bool) (cellRow, wallRow []byte) {
+	width := s.Width()
+	cellRow = make([]byte, width)
+	wallRow = make([]byte, width)
+	for x := range cellRow {
+		cellRow[x] = '#'
+		wallRow[x] = '#'
+	}
+
+	// Every cell without a set gets a new one of its own, reusing ids no longer in use
+	for i := range s.used {
+		s.used[i] = false
+	}
+	for _, set := range s.sets {
+		if set >= 0 {
+			s.used[set] = true
+		}
+	}
+	free := 0
+	for x := range s.sets {
+		if s.sets[x] < 0 {
+			for s.used[free] {
+				free++
+			}
+			s.sets[x] = free
+			s.used[free] = true
+		}
+	}
+
+	// Randomly join neighbouring cells in different sets. The last row must
+	// join everything that's left
+	for i := range s.joined {
+		s.joined[i] = i
+	}
+	for x := 0; x < s.cells; x++ {
+		cellRow[2*x+1] = '.'
+		if x+1 < s.cells && s.joined.find(s.sets[x]) != s.joined.find(s.sets[x+1]) && (last || s.rng.Intn(2) == 0) {
+			s.joined.union(s.sets[x], s.sets[x+1])
+			cellRow[2*x+2] = '.'
+		}
+	}
+	if last {
+		return cellRow, wallRow
+	}
+
+	// Each set needs at least one passage down to the next row. Pick one cell
+	// from each set at random to guarantee it, then add some more at random
+	for x := range s.sets {
+		s.sets[x] = s.joined.find(s.sets[x])
+		s.seen[s.sets[x]] = 0
+	}
+	for x, set := range s.sets {
+		s.seen[set]++
+		if s.rng.Intn(s.seen[set]) == 0 {
+			s.chosen[set] = x
+		}
+	}
+	for x, set := range s.sets {
+		if x == s.chosen[set] || s.rng.Intn(3) == 0 {
+			wallRow[2*x+1] = '.'
+		} else {
+			s.sets[x] = -1 // Cells not joined from above start a new set
+		}
+	}
+
+	return cellRow, wallRow
+}
+
+// StreamMaze writes a maze of the given size straight to w, one line per row,
+// without ever holding the whole maze in memory
+func StreamMaze(w io.Writer, width, height int, seed int64) error {
+	if width%2 == 0 {
+		width++
+	}
+	if height%2 == 0 {
+		height++
+	}
+	stream := NewMazeStream(width, seed)
+	out := bufio.NewWriter(w)
+	writeRow := func(row []byte) {
+		out.Write(row)
+		out.WriteByte('\n')
+	}
+
+	border := make([]byte, stream.Width())
+	for x := range border {
+		border[x] = '#'
+	}
+	writeRow(border)
+
+	cellRows := (height - 1) / 2
+	for y := 0; y < cellRows; y++ {
+		// The wall row below the last cells is solid, so forms the bottom border
+		cellRow, wallRow := stream.NextRow(y == cellRows-1)
+		writeRow(cellRow)
+		writeRow(wallRow)
+	}
+
+	// bufio.Writer remembers the first error, so it only needs checking once
+	return out.Flush()
+}
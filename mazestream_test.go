@@ -0,0 +1,26 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// benchmarkMazeSize is the size in tiles of the benchmark mazes, a little over
+// four million cells
+const benchmarkMazeSize = 4097
+
+func BenchmarkCarveBacktracker(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewMazeGenerator(benchmarkMazeSize, benchmarkMazeSize, int64(i)).GenerateMaze()
+	}
+}
+
+func BenchmarkStreamMaze(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := StreamMaze(io.Discard, benchmarkMazeSize, benchmarkMazeSize, int64(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const minMazeSize = 5 // Smallest maze width/height selectable from the menu
+
+// generationMenu is the M-key menu for choosing how new mazes are generated
+type generationMenu struct {
+	open     bool
+	selected int
+	options  MazeOptions // Options being edited, applied when a maze is generated
+}
+
+// menuItem is a single adjustable line of the generation menu
+type menuItem struct {
+	label  string
+	value  func(o *MazeOptions) string
+	adjust func(o *MazeOptions, delta int)
+}
+
+var generationMenuItems = []menuItem{
+	{
+		label: "Generator",
+		value: func(o *MazeOptions) string {
+			if o.Algorithm == "wfc" {
+				return fmt.Sprintf("%s (up to %d tiles)", o.Algorithm, wfcMaxTiles)
+			}
+			return o.Algorithm
+		},
+		adjust: func(o *MazeOptions, delta int) {
+			names := GeneratorNames()
+			index := 0
+			for i, name := range names {
+				if name == o.Algorithm {
+					index = i
+				}
+			}
+			o.Algorithm = names[(index+delta+len(names))%len(names)]
+		},
+	},
+	{
+		label:  "Width",
+		value:  func(o *MazeOptions) string { return fmt.Sprint(o.Width) },
+		adjust: func(o *MazeOptions, delta int) { o.Width = max(minMazeSize, o.Width+delta*2) },
+	},
+	{
+		label:  "Height",
+		value:  func(o *MazeOptions) string { return fmt.Sprint(o.Height) },
+		adjust: func(o *MazeOptions, delta int) { o.Height = max(minMazeSize, o.Height+delta*2) },
+	},
+	{
+		label: "Corridor width",
+		value: func(o *MazeOptions) string {
+			width := max(1, o.CorridorWidth)
+			return fmt.Sprintf("%d (%.0fpx clear)", width, float64(width*tileSize)/2-o.MarbleRadius)
+		},
+		adjust: func(o *MazeOptions, delta int) { o.CorridorWidth = min(4, max(1, o.CorridorWidth+delta)) },
+	},
+	{
+		label:  "Wall thickness",
+		value:  func(o *MazeOptions) string { return fmt.Sprint(max(1, o.WallThickness)) },
+		adjust: func(o *MazeOptions, delta int) { o.WallThickness = min(3, max(1, o.WallThickness+delta)) },
+	},
+	{
+		label:  "Floors",
+		value:  func(o *MazeOptions) string { return fmt.Sprint(max(1, o.Floors)) },
+		adjust: func(o *MazeOptions, delta int) { o.Floors = min(4, max(1, o.Floors+delta)) },
+	},
+	{
+		label:  "Vaults",
+		value:  func(o *MazeOptions) string { return fmt.Sprint(o.Prefabs) },
+		adjust: func(o *MazeOptions, delta int) { o.Prefabs = min(5, max(0, o.Prefabs+delta)) },
+	},
+	{
+		label: "Special tiles",
+		value: func(o *MazeOptions) string { return fmt.Sprintf("%.0f%%", o.SpecialTileDensity*100) },
+		adjust: func(o *MazeOptions, delta int) {
+			o.SpecialTileDensity = min(0.5, max(0, o.SpecialTileDensity+float64(delta)*0.05))
+		},
+	},
+	{
+		label: "Dead ends removed",
+		value: func(o *MazeOptions) string { return fmt.Sprintf("%.0f%%", o.BraidFraction*100) },
+		adjust: func(o *MazeOptions, delta int) {
+			o.BraidFraction = min(1, max(0, o.BraidFraction+float64(delta)*0.1))
+		},
+	},
+	{
+		label: "Extra loops",
+		value: func(o *MazeOptions) string { return fmt.Sprintf("%.0f%%", o.LoopDensity*100) },
+		adjust: func(o *MazeOptions, delta int) {
+			o.LoopDensity = min(0.5, max(0, o.LoopDensity+float64(delta)*0.02))
+		},
+	},
+	{
+		label: "Holes",
+		value: func(o *MazeOptions) string { return fmt.Sprintf("%.0f%%", o.HoleDensity*100) },
+		adjust: func(o *MazeOptions, delta int) {
+			o.HoleDensity = min(0.3, max(0, o.HoleDensity+float64(delta)*0.02))
+		},
+	},
+	{
+		label:  "Hole clearance",
+		value:  func(o *MazeOptions) string { return fmt.Sprintf("%.0fpx", o.HoleClearance) },
+		adjust: func(o *MazeOptions, delta int) { o.HoleClearance = min(64, max(0, o.HoleClearance+float64(delta)*4)) },
+	},
+	{
+		label: "Difficulty",
+		value: func(o *MazeOptions) string {
+			if o.Difficulty == "" {
+				return difficultyBands[0].Name
+			}
+			return o.Difficulty
+		},
+		adjust: func(o *MazeOptions, delta int) {
+			names := []string{}
+			for _, named := range difficultyBands {
+				names = append(names, named.Name)
+			}
+			names = append(names, campaignDifficulty)
+			index := 0
+			for i, name := range names {
+				if name == o.Difficulty {
+					index = i
+				}
+			}
+			o.Difficulty = names[(index+delta+len(names))%len(names)]
+		},
+	},
+}
+
+// openMenu shows the generation menu, starting from the current options
+func (g *Game) openMenu() {
+	g.menu.open = true
+	g.menu.options = g.mazeOptions
+}
+
+// updateMenu handles keyboard input while the generation menu is open.
+// Up/Down pick an item, Left/Right change it, Enter generates a new maze and Escape cancels
+func (g *Game) updateMenu() {
+	items := len(generationMenuItems)
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) || inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		g.menu.selected = (g.menu.selected + items - 1) % items
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) || inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		g.menu.selected = (g.menu.selected + 1) % items
+	}
+
+	item := generationMenuItems[g.menu.selected]
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		item.adjust(&g.menu.options, -1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || inpututil.IsKeyJustPressed(ebiten.KeyD) {
+		item.adjust(&g.menu.options, 1)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.menu.open = false
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter) || inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.menu.open = false
+		g.mazeOptions = g.menu.options
+		g.mazeOptions.Seed = newSeed()
+		g.generateNewMaze()
+	}
+}
+
+// drawMenu draws the generation menu in the middle of the screen
+func (g *Game) drawMenu(screen *ebiten.Image) {
+	const lineHeight = 16
+	width := 360
+	height := (len(generationMenuItems) + 3) * lineHeight
+	x := (g.screenWidth - width) / 2
+	y := (g.screenHeight - height) / 2
+
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{0, 0, 0, 200}, false)
+	ebitenutil.DebugPrintAt(screen, "New maze", x+8, y+4)
+	for i, item := range generationMenuItems {
+		cursor := " "
+		if i == g.menu.selected {
+			cursor = ">"
+		}
+		line := fmt.Sprintf("%s %-18s < %s >", cursor, item.label, item.value(&g.menu.options))
+		ebitenutil.DebugPrintAt(screen, line, x+8, y+4+(i+1)*lineHeight)
+	}
+	ebitenutil.DebugPrintAt(screen, "Enter: generate  Esc: cancel", x+8, y+4+(len(generationMenuItems)+1)*lineHeight)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"image"
+	"io/fs"
+	"log"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Prefab is a hand-built set piece that's stamped into generated mazes.
+//
+// Prefabs are ASCII grids in the level format, with an odd width and height so
+// they line up with the walls and corridors of a maze. Their border is wall,
+// apart from entrances: open tiles at odd positions along each side, which the
+// rest of the maze is joined onto
+type Prefab struct {
+	Name  string
+	Lines []string
+}
+
+// vault is a prefab placed in a maze, at the logical grid position of its top left corner
+type vault struct {
+	prefab *Prefab
+	at     image.Point
+}
+
+// LoadPrefabs reads every prefab in a directory of the given filesystem.
+// Prefabs that can't be stamped into a maze are skipped with a warning
+func LoadPrefabs(fsys fs.FS, dir string) []Prefab {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.txt"))
+	if err != nil {
+		log.Printf("Failed to list prefabs in %s: %v", dir, err)
+		return nil
+	}
+	sort.Strings(names)
+
+	var prefabs []Prefab
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			log.Printf("Failed to read prefab %s: %v", name, err)
+			continue
+		}
+		prefab := Prefab{
+			Name:  strings.TrimSuffix(path.Base(name), ".txt"),
+			Lines: strings.Split(strings.TrimRight(string(data), "\n"), "\n"),
+		}
+		if err := prefab.validate(); err != "" {
+			log.Printf("Skipping prefab %s: %s", name, err)
+			continue
+		}
+		prefabs = append(prefabs, prefab)
+	}
+	return prefabs
+}
+
+// Width returns the width of the prefab in tiles
+func (p *Prefab) Width() int {
+	return len(p.Lines[0])
+}
+
+// Height returns the height of the prefab in tiles
+func (p *Prefab) Height() int {
+	return len(p.Lines)
+}
+
+// validate checks that the prefab fits the maze grid, returning what's wrong with it if it doesn't
+func (p *Prefab) validate() string {
+	if len(p.Lines) < 3 || len(p.Lines)%2 == 0 || len(p.Lines[0]) < 3 || len(p.Lines[0])%2 == 0 {
+		return "width and height must be odd, and at least 3"
+	}
+	for _, line := range p.Lines {
+		if len(line) != p.Width() {
+			return "rows must all be the same width"
+		}
+	}
+	w, h := p.Width(), p.Height()
+	for y, line := range p.Lines {
+		for x := range line {
+			onBorder := x == 0 || y == 0 || x == w-1 || y == h-1
+			if onBorder && asciiWalkable(line[x]) && (x%2 == 0) == (y%2 == 0) {
+				return "entrances must be at odd positions along the sides"
+			}
+		}
+	}
+	if len(p.entrances()) == 0 {
+		return "there must be at least one entrance"
+	}
+	return ""
+}
+
+// entrances returns the open tiles on the prefab's border, along with the step
+// out of the prefab from each
+func (p *Prefab) entrances() (entrances [][2]image.Point) {
+	w, h := p.Width(), p.Height()
+	for y, line := range p.Lines {
+		for x := range line {
+			if !asciiWalkable(line[x]) {
+				continue
+			}
+			at := image.Pt(x, y)
+			switch {
+			case y == 0:
+				entrances = append(entrances, [2]image.Point{at, cellSteps[0]})
+			case x == w-1:
+				entrances = append(entrances, [2]image.Point{at, cellSteps[1]})
+			case y == h-1:
+				entrances = append(entrances, [2]image.Point{at, cellSteps[2]})
+			case x == 0:
+				entrances = append(entrances, [2]image.Point{at, cellSteps[3]})
+			}
+		}
+	}
+	return entrances
+}
+
+// ReservePrefabs picks where up to count prefabs go in the maze, chosen at
+// random from the given ones, before it's carved. Vaults are kept a corridor
+// apart from each other and the edge of the maze, so it can be carved all the
+// way around them. Fewer are placed if they don't fit
+func (mg *MazeGenerator) ReservePrefabs(prefabs []Prefab, count int) {
+	mg.vaults = nil
+	if len(prefabs) == 0 {
+		return
+	}
+	const attempts = 20
+	for i := 0; i < count; i++ {
+		prefab := &prefabs[mg.rng.Intn(len(prefabs))]
+		w, h := prefab.Width(), prefab.Height()
+		for try := 0; try < attempts; try++ {
+			// Vaults start on an even row and column, so their corridors line up
+			// with the maze's. Checked before halving, as that rounds -1 up to 0
+			if mg.width-w-3 < 0 || mg.height-h-3 < 0 {
+				break // Too big for this maze
+			}
+			spanX, spanY := (mg.width-w-3)/2, (mg.height-h-3)/2
+			at := image.Pt(2+2*mg.rng.Intn(spanX+1), 2+2*mg.rng.Intn(spanY+1))
+			area := image.Rect(at.X-2, at.Y-2, at.X+w+2, at.Y+h+2)
+			clear := true
+			for _, v := range mg.vaults {
+				if area.Overlaps(v.bounds()) {
+					clear = false
+				}
+			}
+			if clear {
+				mg.vaults = append(mg.vaults, vault{prefab, at})
+				break
+			}
+		}
+	}
+}
+
+// bounds returns the tiles the vault covers on the logical grid
+func (v vault) bounds() image.Rectangle {
+	return image.Rect(v.at.X, v.at.Y, v.at.X+v.prefab.Width(), v.at.Y+v.prefab.Height())
+}
+
+// inVault checks if a tile of the logical grid is part of a vault
+func (mg *MazeGenerator) inVault(x, y int) bool {
+	for _, v := range mg.vaults {
+		if image.Pt(x, y).In(v.bounds()) {
+			return true
+		}
+	}
+	return false
+}
+
+// inExpandedVault checks if a tile of the expanded maze is part of a vault
+func (mg *MazeGenerator) inExpandedVault(p image.Point) bool {
+	for _, v := range mg.vaults {
+		b := v.bounds()
+		minX, _ := mg.expandSpan(b.Min.X)
+		minY, _ := mg.expandSpan(b.Min.Y)
+		maxX, width := mg.expandSpan(b.Max.X - 1)
+		maxY, height := mg.expandSpan(b.Max.Y - 1)
+		if p.In(image.Rect(minX, minY, maxX+width, maxY+height)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stampVaults copies the reserved prefabs over the carved maze, then rejoins
+// the passages they cut through. Vaults are joined on at their entrances, and
+// walls are knocked down at random until everything is connected
+func (mg *MazeGenerator) stampVaults() {
+	// Vaults and the tiles outside their entrances have to be inside the border
+	inside := image.Rect(1, 1, mg.width-1, mg.height-1)
+	mg.vaults = slices.DeleteFunc(mg.vaults, func(v vault) bool {
+		if !v.bounds().Inset(-1).In(inside) {
+			log.Printf("Skipping prefab %s, as it doesn't fit in the maze at %v", v.prefab.Name, v.at)
+			return true
+		}
+		return false
+	})
+	if len(mg.vaults) == 0 {
+		return
+	}
+	w, h := mg.cellsWide(), mg.cellsHigh()
+	sets := newDisjointSet(w * h)
+	taken := make([]bool, w*h)
+
+	for _, v := range mg.vaults {
+		for y, line := range v.prefab.Lines {
+			copy(mg.maze[v.at.Y+y][v.at.X:], line)
+		}
+
+		// The cells outside the vault's entrances are joined through it if
+		// there's a way between the entrances inside
+		b := v.bounds()
+		for cy := b.Min.Y / 2; cy < (b.Max.Y-1)/2; cy++ {
+			for cx := b.Min.X / 2; cx < (b.Max.X-1)/2; cx++ {
+				taken[cy*w+cx] = true
+			}
+		}
+		entrances := v.prefab.entrances()
+		for i, entrance := range entrances {
+			outside := v.at.Add(entrance[0]).Add(entrance[1])
+			mg.maze[outside.Y][outside.X] = '.'
+			dist := DistanceField(v.prefab.Lines, entrance[0])
+			for _, other := range entrances[:i] {
+				if dist[other[0].Y][other[0].X] >= 0 {
+					joined := v.at.Add(other[0]).Add(other[1])
+					sets.union((outside.Y/2)*w+outside.X/2, (joined.Y/2)*w+joined.X/2)
+				}
+			}
+		}
+	}
+
+	// Bridges join the corridors over and under them separately, unless a
+	// vault has cut one of them off
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			cell := image.Pt(2*cx+1, 2*cy+1)
+			if _, isBridge := asciiBridge(mg.maze[cell.Y][cell.X]); !isBridge || taken[cy*w+cx] {
+				continue
+			}
+			if mg.inVault(cell.X-1, cell.Y) || mg.inVault(cell.X+1, cell.Y) || mg.inVault(cell.X, cell.Y-1) || mg.inVault(cell.X, cell.Y+1) {
+				mg.maze[cell.Y][cell.X] = '.'
+				continue
+			}
+			taken[cy*w+cx] = true
+			sets.union((cy-1)*w+cx, (cy+1)*w+cx)
+			sets.union(cy*w+cx-1, cy*w+cx+1)
+		}
+	}
+
+	// Passages the maze already has outside the vaults
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			if taken[cy*w+cx] {
+				continue
+			}
+			if cx+1 < w && !taken[cy*w+cx+1] && mg.maze[2*cy+1][2*cx+2] != '#' {
+				sets.union(cy*w+cx, cy*w+cx+1)
+			}
+			if cy+1 < h && !taken[(cy+1)*w+cx] && mg.maze[2*cy+2][2*cx+1] != '#' {
+				sets.union(cy*w+cx, (cy+1)*w+cx)
+			}
+		}
+	}
+
+	joinAtRandom(mg, sets, taken)
+}
+
+// prefabCache holds the prefabs once they've been loaded
+var prefabCache []Prefab
+
+// builtinPrefabs returns the set pieces that come with the game
+func builtinPrefabs() []Prefab {
+	if prefabCache == nil {
+		prefabCache = LoadPrefabs(assetsFS, "assets/prefabs")
+	}
+	return prefabCache
+}
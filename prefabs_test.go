@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPrefabsFitSmallMazes(t *testing.T) {
+	sizes := []struct{ width, height int }{{9, 9}, {15, 11}, {21, 15}, {37, 19}, {41, 25}}
+	for _, size := range sizes {
+		for corridor := 1; corridor <= 3; corridor++ {
+			for vaults := 1; vaults <= 3; vaults++ {
+				t.Run(fmt.Sprintf("%dx%d/corridor%d/vaults%d", size.width, size.height, corridor, vaults), func(t *testing.T) {
+					for seed := int64(0); seed < 50; seed++ {
+						maze := CreateMazeWithSpecialTiles(MazeOptions{
+							Width:         size.width,
+							Height:        size.height,
+							Seed:          seed,
+							CorridorWidth: corridor,
+							Prefabs:       vaults,
+							MarbleRadius:  15,
+						})
+						if len(maze.Solution) == 0 {
+							t.Errorf("seed %d: no route from start to goal", seed)
+						}
+					}
+				})
+			}
+		}
+	}
+}
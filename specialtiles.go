@@ -0,0 +1,156 @@
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// SpecialTileWeights tune where special tiles are placed. Each weight is how
+// strongly tiles in that kind of spot are favoured; zero turns it off
+type SpecialTileWeights struct {
+	Boost       float64 // Fast tiles on long straight runs of the solution, heading towards the goal
+	BrakeBefore float64 // Slow tiles on the solution just before it turns
+	Junction    float64 // Mildly slow tiles where corridors branch, giving time to choose
+	SideBranch  float64 // Mildly slow tiles off the solution. Fast tiles never go there, as branches lead to dead ends
+	MinStraight int     // Shortest straight run of the solution, in tiles, that gets boosts
+}
+
+// DefaultSpecialTileWeights are used when no weights are given
+var DefaultSpecialTileWeights = SpecialTileWeights{
+	Boost:       3,
+	BrakeBefore: 2,
+	Junction:    1,
+	SideBranch:  0.5,
+	MinStraight: 4,
+}
+
+// specialTileSpot is a floor tile that could become a special tile
+type specialTileSpot struct {
+	p      image.Point
+	tile   byte
+	weight float64
+}
+
+// PlaceSpecialTiles turns a fraction of the floor tiles into speed tiles, chosen
+// by the shape of the corridors around them and the solution through the maze
+func (mg *MazeGenerator) PlaceSpecialTiles(result *MazeResult, density float64, weights SpecialTileWeights) {
+	result.Lines = mg.placeSpecialTiles(result.Lines, result.Solution, density, weights)
+}
+
+// placeSpecialTiles picks spots for special tiles along a route, then chooses
+// between them at random by weight
+func (mg *MazeGenerator) placeSpecialTiles(lines []string, route []image.Point, density float64, weights SpecialTileWeights) []string {
+	if density <= 0 || density > 1 {
+		return lines
+	}
+	if weights == (SpecialTileWeights{}) {
+		weights = DefaultSpecialTileWeights
+	}
+
+	floor := 0
+	for _, line := range lines {
+		for x := range line {
+			if line[x] == '.' {
+				floor++
+			}
+		}
+	}
+	count := int(math.Round(density * float64(floor)))
+
+	// Weighted sampling without replacement: each spot gets a random key
+	// weighted towards 1, and the spots with the highest keys win
+	var spots []specialTileSpot
+	for _, spot := range specialTileSpots(lines, route, weights) {
+		if !mg.inExpandedVault(spot.p) {
+			spots = append(spots, spot)
+		}
+	}
+	keys := make([]float64, len(spots))
+	for i, spot := range spots {
+		keys[i] = math.Pow(mg.rng.Float64(), 1/spot.weight)
+	}
+	order := make([]int, len(spots))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] > keys[order[b]] })
+
+	grid := make([][]byte, len(lines))
+	for y, line := range lines {
+		grid[y] = []byte(line)
+	}
+	for _, i := range order[:min(count, len(order))] {
+		grid[spots[i].p.Y][spots[i].p.X] = spots[i].tile
+	}
+
+	result := make([]string, len(grid))
+	for y, row := range grid {
+		result[y] = string(row)
+	}
+	return result
+}
+
+// specialTileSpots classifies every floor tile by its place in the maze,
+// returning the ones that suit a special tile
+func specialTileSpots(lines []string, route []image.Point, weights SpecialTileWeights) []specialTileSpot {
+	exits := func(p image.Point) int {
+		count := 0
+		for _, step := range cellSteps {
+			n := p.Add(step)
+			if n.Y >= 0 && n.Y < len(lines) && n.X >= 0 && n.X < len(lines[n.Y]) && asciiWalkable(lines[n.Y][n.X]) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// How many tiles the route carries on straight ahead from each of its tiles
+	run := make([]int, len(route))
+	for i := len(route) - 2; i >= 0; i-- {
+		run[i] = 1
+		if i+2 < len(route) && route[i+1].Sub(route[i]) == route[i+2].Sub(route[i+1]) {
+			run[i] = run[i+1] + 1
+		}
+	}
+
+	var spots []specialTileSpot
+	add := func(p image.Point, tile byte, weight float64) {
+		if weight > 0 && lines[p.Y][p.X] == '.' {
+			spots = append(spots, specialTileSpot{p, tile, weight})
+		}
+	}
+
+	onRoute := map[image.Point]bool{}
+	for i, p := range route {
+		onRoute[p] = true
+		turnsAhead := i+run[i] < len(route)-1 // The route doesn't reach the goal before it turns
+		switch {
+		case i == len(route)-1:
+		case turnsAhead && run[i] <= 2:
+			add(p, '<', weights.BrakeBefore)
+		case run[i] >= weights.MinStraight:
+			tile := byte(')')
+			if run[i] >= weights.MinStraight*2 {
+				tile = '>'
+			}
+			add(p, tile, weights.Boost*float64(run[i])/float64(max(1, weights.MinStraight)))
+		case exits(p) > 2:
+			add(p, '(', weights.Junction)
+		}
+	}
+
+	for y, line := range lines {
+		for x := range line {
+			p := image.Pt(x, y)
+			switch {
+			case onRoute[p]:
+			case exits(p) > 2:
+				add(p, '(', weights.Junction)
+			default:
+				add(p, '(', weights.SideBranch)
+			}
+		}
+	}
+	return spots
+}
@@ -0,0 +1,65 @@
+//go:build !wasm
+
+// storage.go keeps small records between runs, as JSON files in the user's config directory
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// storageDir returns the directory records are kept in
+func storageDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "TiltMan"), nil
+}
+
+// loadRecord reads the record stored under key into v, returning whether there was one
+func loadRecord(key string, v any) bool {
+	dir, err := storageDir()
+	if err != nil {
+		log.Printf("Failed to find where records are stored: %v", err)
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to read record %s: %v", key, err)
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("Failed to parse record %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// saveRecord stores v under key, replacing what was there
+func saveRecord(key string, v any) {
+	dir, err := storageDir()
+	if err != nil {
+		log.Printf("Failed to find where records are stored: %v", err)
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode record %s: %v", key, err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Failed to create %s: %v", dir, err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, key+".json"), data, 0o644); err != nil {
+		log.Printf("Failed to write record %s: %v", key, err)
+	}
+}
@@ -0,0 +1,46 @@
+// storage_wasm.go keeps small records between runs in the browser's local storage
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"syscall/js"
+)
+
+// localStorage returns the browser's local storage, which may be missing or blocked
+func localStorage() (js.Value, bool) {
+	storage := js.Global().Get("localStorage")
+	return storage, storage.Truthy()
+}
+
+// loadRecord reads the record stored under key into v, returning whether there was one
+func loadRecord(key string, v any) bool {
+	storage, ok := localStorage()
+	if !ok {
+		return false
+	}
+	item := storage.Call("getItem", "TiltMan."+key)
+	if item.IsNull() {
+		return false
+	}
+	if err := json.Unmarshal([]byte(item.String()), v); err != nil {
+		log.Printf("Failed to parse record %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// saveRecord stores v under key, replacing what was there
+func saveRecord(key string, v any) {
+	storage, ok := localStorage()
+	if !ok {
+		log.Printf("No local storage to save record %s in", key)
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode record %s: %v", key, err)
+		return
+	}
+	storage.Call("setItem", "TiltMan."+key, string(data))
+}
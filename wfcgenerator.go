@@ -0,0 +1,531 @@
+package main
+
+import (
+	"image"
+	"io/fs"
+	"log"
+	"math"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+)
+
+// wfcMaxTiles is the largest level, in tiles, made with wave function collapse.
+// Its state grows with the area times the number of patterns, which is in the
+// hundreds for the sample maps, so bigger levels would take too much memory
+const wfcMaxTiles = 4096
+
+// WFCGenerator creates levels in the style of a set of example maps, using the
+// overlapping model of Wave Function Collapse. Every NxN block of tiles in the
+// examples becomes a pattern, and the output is built so that every NxN block of
+// it is one of those patterns, overlapping its neighbours consistently
+type WFCGenerator struct {
+	width   int
+	height  int
+	rng     *rand.Rand
+	samples [][]string
+
+	PatternSize     int     // Width and height of the patterns learned from the samples
+	Symmetry        bool    // Also learn rotated and reflected copies of the samples
+	MaxBacktracks   int     // Choices undone before an attempt is abandoned
+	Attempts        int     // Attempts before giving up altogether
+	MinOpenFraction float64 // Smallest fraction of the map the reachable area may cover
+
+	patterns []string   // Tiles of each pattern, row by row
+	weights  []int      // Number of times each pattern appears in the samples
+	compat   [][4][]int // Patterns that may sit next to each pattern, for each of cellSteps
+}
+
+// wfcWave is the state of a single attempt: which patterns are still possible
+// in each cell, where a cell is the position of the top left of a pattern
+type wfcWave struct {
+	g         *WFCGenerator
+	width     int     // Cells across
+	height    int     // Cells down
+	possible  []bool  // Whether each pattern is possible in each cell, indexed by cell*patterns+pattern
+	remaining []int   // Number of patterns possible in each cell
+	support   []int16 // For each cell, pattern and direction, the patterns possible in the neighbour that side which it can sit next to
+	trail     []wfcBan
+	pending   []wfcBan // Bans waiting to be made by propagate
+}
+
+// wfcBan is a pattern ruled out for a cell
+type wfcBan struct {
+	cell    int
+	pattern int
+}
+
+// wfcDecision is a pattern chosen for a cell, which can be undone when it leads to a contradiction
+type wfcDecision struct {
+	cell    int
+	pattern int
+	mark    int // Length of the ban trail before the choice was made
+}
+
+// NewWFCGenerator creates a new generator with the specified dimensions, which
+// makes levels in the style of the sample maps. The same seed and samples
+// always produce the same level
+func NewWFCGenerator(width, height int, seed int64, samples [][]string) *WFCGenerator {
+	return &WFCGenerator{
+		width:           width,
+		height:          height,
+		rng:             rand.New(rand.NewSource(seed)),
+		samples:         samples,
+		PatternSize:     3,
+		Symmetry:        true,
+		MaxBacktracks:   500,
+		Attempts:        10,
+		MinOpenFraction: 0.2,
+	}
+}
+
+// LoadSampleMaps loads every .txt file in a directory as an ASCII sample map, in name order
+func LoadSampleMaps(fsys fs.FS, dir string) [][]string {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.txt"))
+	if err != nil {
+		log.Printf("Failed to list sample maps in %s: %v", dir, err)
+		return nil
+	}
+	sort.Strings(names)
+
+	var samples [][]string
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			log.Printf("Failed to read sample map %s: %v", name, err)
+			continue
+		}
+		samples = append(samples, strings.Split(strings.TrimRight(string(data), "\n"), "\n"))
+	}
+	return samples
+}
+
+// Generate creates a level, returning one string per row in the ASCII format
+// used by NewGameMap, or nil if no level could be made from the samples or
+// it's bigger than wfcMaxTiles
+func (g *WFCGenerator) Generate() []string {
+	n := g.PatternSize
+	if g.width < n || g.height < n {
+		return nil
+	}
+	if g.width*g.height > wfcMaxTiles {
+		log.Printf("A %dx%d level is too big for wave function collapse, which goes up to %d tiles", g.width, g.height, wfcMaxTiles)
+		return nil
+	}
+	g.learn()
+	if len(g.patterns) == 0 || len(g.patterns) > math.MaxInt16 {
+		return nil
+	}
+
+	for attempt := 0; attempt < g.Attempts; attempt++ {
+		grid, ok := g.collapse()
+		if !ok {
+			continue
+		}
+		if lines := g.connect(grid); lines != nil {
+			return lines
+		}
+	}
+	return nil
+}
+
+// learn extracts the patterns from the samples, and works out which can overlap
+func (g *WFCGenerator) learn() {
+	n := g.PatternSize
+	g.patterns, g.weights = nil, nil
+	index := map[string]int{}
+	for _, sample := range g.samples {
+		for _, variant := range g.variants(sample) {
+			for y := 0; y+n <= len(variant); y++ {
+				for x := 0; x+n <= len(variant[y]); x++ {
+					var pattern strings.Builder
+					for dy := 0; dy < n; dy++ {
+						pattern.WriteString(variant[y+dy][x : x+n])
+					}
+					key := pattern.String()
+					if i, ok := index[key]; ok {
+						g.weights[i]++
+						continue
+					}
+					index[key] = len(g.patterns)
+					g.patterns = append(g.patterns, key)
+					g.weights = append(g.weights, 1)
+				}
+			}
+		}
+	}
+
+	g.compat = make([][4][]int, len(g.patterns))
+	for p := range g.patterns {
+		for d, step := range cellSteps {
+			for q := range g.patterns {
+				if g.overlaps(p, q, step) {
+					g.compat[p][d] = append(g.compat[p][d], q)
+				}
+			}
+		}
+	}
+}
+
+// variants returns a sample as a rectangular grid, along with its rotations and
+// reflections if Symmetry is enabled. The start and goal are learned as plain floor
+func (g *WFCGenerator) variants(sample []string) [][]string {
+	width := 0
+	for _, line := range sample {
+		width = max(width, len(line))
+	}
+	grid := make([]string, len(sample))
+	for y, line := range sample {
+		line = strings.NewReplacer("S", ".", "G", ".").Replace(line)
+		grid[y] = line + strings.Repeat("#", width-len(line))
+	}
+
+	variants := [][]string{grid}
+	if !g.Symmetry {
+		return variants
+	}
+	for i := 0; i < 3; i++ {
+		variants = append(variants, rotateGrid(variants[len(variants)-1]))
+	}
+	for i := 0; i < 4; i++ {
+		variants = append(variants, reflectGrid(variants[i]))
+	}
+	return variants
+}
+
+// rotateGrid returns a grid rotated a quarter turn clockwise
+func rotateGrid(grid []string) []string {
+	if len(grid) == 0 {
+		return grid
+	}
+	rotated := make([]string, len(grid[0]))
+	for x := range rotated {
+		row := make([]byte, len(grid))
+		for y := range grid {
+			row[len(grid)-1-y] = grid[y][x]
+		}
+		rotated[x] = string(row)
+	}
+	return rotated
+}
+
+// reflectGrid returns a grid flipped left to right
+func reflectGrid(grid []string) []string {
+	reflected := make([]string, len(grid))
+	for y, line := range grid {
+		row := []byte(line)
+		for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+			row[i], row[j] = row[j], row[i]
+		}
+		reflected[y] = string(row)
+	}
+	return reflected
+}
+
+// overlaps checks if pattern q, offset from pattern p by step, agrees with p
+// everywhere the two overlap
+func (g *WFCGenerator) overlaps(p, q int, step image.Point) bool {
+	n := g.PatternSize
+	for y := max(0, step.Y); y < min(n, n+step.Y); y++ {
+		for x := max(0, step.X); x < min(n, n+step.X); x++ {
+			if g.patterns[p][y*n+x] != g.patterns[q][(y-step.Y)*n+x-step.X] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// collapse runs Wave Function Collapse, repeatedly choosing a pattern for the most
+// constrained cell and propagating the consequences. When a choice leads to a
+// contradiction it's undone and ruled out. Returns the tile grid, or false if
+// the attempt ran out of backtracks
+func (g *WFCGenerator) collapse() ([][]byte, bool) {
+	w := g.newWave()
+	if !w.propagate() {
+		return nil, false // The samples can't tile a map this size
+	}
+
+	var decisions []wfcDecision
+	backtracks := 0
+	for {
+		cell := w.mostConstrained(g.rng)
+		if cell < 0 {
+			break // Fully collapsed
+		}
+		pattern := w.choose(cell, g.rng)
+		decisions = append(decisions, wfcDecision{cell, pattern, len(w.trail)})
+		for p := range g.patterns {
+			if p != pattern && w.possible[cell*len(g.patterns)+p] {
+				w.ban(cell, p)
+			}
+		}
+
+		for ok := w.propagate(); !ok; {
+			if len(decisions) == 0 || backtracks >= g.MaxBacktracks {
+				return nil, false
+			}
+			backtracks++
+
+			// Undo the last choice, then rule it out
+			last := decisions[len(decisions)-1]
+			decisions = decisions[:len(decisions)-1]
+			w.undo(last.mark)
+			w.ban(last.cell, last.pattern)
+			ok = w.propagate()
+		}
+	}
+
+	// Each tile comes from the pattern at its position, or the last one that covers it at the edges
+	n := g.PatternSize
+	grid := make([][]byte, g.height)
+	for y := range grid {
+		grid[y] = make([]byte, g.width)
+		for x := range grid[y] {
+			px, py := min(x, w.width-1), min(y, w.height-1)
+			pattern := g.patterns[w.decided(py*w.width+px)]
+			grid[y][x] = pattern[(y-py)*n+x-px]
+		}
+	}
+	return grid, true
+}
+
+// newWave creates the state for an attempt, with every pattern possible everywhere
+func (g *WFCGenerator) newWave() *wfcWave {
+	n := g.PatternSize
+	patterns := len(g.patterns)
+	w := &wfcWave{
+		g:      g,
+		width:  g.width - n + 1,
+		height: g.height - n + 1,
+	}
+	cells := w.width * w.height
+	w.possible = make([]bool, cells*patterns)
+	w.remaining = make([]int, cells)
+	w.support = make([]int16, cells*patterns*4)
+	for cell := 0; cell < cells; cell++ {
+		w.remaining[cell] = patterns
+		for p := 0; p < patterns; p++ {
+			w.possible[cell*patterns+p] = true
+			for d := range cellSteps {
+				w.support[(cell*patterns+p)*4+d] = int16(len(g.compat[p][d]))
+			}
+		}
+	}
+
+	// Patterns that can't have anything next to them are ruled out straight away
+	for cell := 0; cell < cells; cell++ {
+		for p := 0; p < patterns; p++ {
+			for d := range cellSteps {
+				if w.neighbour(cell, d) >= 0 && len(g.compat[p][d]) == 0 && w.possible[cell*patterns+p] {
+					w.ban(cell, p)
+				}
+			}
+		}
+	}
+	return w
+}
+
+// neighbour returns the cell next to the given one in the direction of cellSteps[d], or -1 at the edges
+func (w *wfcWave) neighbour(cell, d int) int {
+	x, y := cell%w.width+cellSteps[d].X, cell/w.width+cellSteps[d].Y
+	if x < 0 || y < 0 || x >= w.width || y >= w.height {
+		return -1
+	}
+	return y*w.width + x
+}
+
+// ban rules out a pattern for a cell, removing its support from its neighbours.
+// Patterns left with no support are queued to be banned by propagate
+func (w *wfcWave) ban(cell, p int) {
+	patterns := len(w.g.patterns)
+	w.possible[cell*patterns+p] = false
+	w.remaining[cell]--
+	w.trail = append(w.trail, wfcBan{cell, p})
+	for d := range cellSteps {
+		n := w.neighbour(cell, d)
+		if n < 0 {
+			continue
+		}
+		// The neighbour's patterns lose support from this side, which is opposite to d from their point of view
+		for _, q := range w.g.compat[p][d] {
+			i := (n*patterns+q)*4 + (d+2)%4
+			w.support[i]--
+			if w.support[i] == 0 && w.possible[n*patterns+q] {
+				w.pending = append(w.pending, wfcBan{n, q})
+			}
+		}
+	}
+}
+
+// propagate bans every pattern that has lost all support from a neighbour,
+// until nothing more changes. Returns false on a contradiction, where a cell
+// has no possible patterns left
+func (w *wfcWave) propagate() bool {
+	patterns := len(w.g.patterns)
+	for len(w.pending) > 0 {
+		next := w.pending[len(w.pending)-1]
+		w.pending = w.pending[:len(w.pending)-1]
+		if !w.possible[next.cell*patterns+next.pattern] {
+			continue
+		}
+		w.ban(next.cell, next.pattern)
+		if w.remaining[next.cell] == 0 {
+			w.pending = w.pending[:0]
+			return false
+		}
+	}
+	for _, remaining := range w.remaining {
+		if remaining == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// undo reverses every ban since the change trail was the given length
+func (w *wfcWave) undo(mark int) {
+	patterns := len(w.g.patterns)
+	for len(w.trail) > mark {
+		last := w.trail[len(w.trail)-1]
+		w.trail = w.trail[:len(w.trail)-1]
+		w.possible[last.cell*patterns+last.pattern] = true
+		w.remaining[last.cell]++
+		for d := range cellSteps {
+			if n := w.neighbour(last.cell, d); n >= 0 {
+				for _, q := range w.g.compat[last.pattern][d] {
+					w.support[(n*patterns+q)*4+(d+2)%4]++
+				}
+			}
+		}
+	}
+}
+
+// mostConstrained returns the undecided cell with the fewest possible patterns,
+// picking at random between ties, or -1 if every cell is decided
+func (w *wfcWave) mostConstrained(rng *rand.Rand) int {
+	best, bestCount, ties := -1, 0, 0
+	for cell, count := range w.remaining {
+		if count <= 1 {
+			continue
+		}
+		switch {
+		case best < 0 || count < bestCount:
+			best, bestCount, ties = cell, count, 1
+		case count == bestCount:
+			ties++
+			if rng.Intn(ties) == 0 {
+				best = cell
+			}
+		}
+	}
+	return best
+}
+
+// choose picks one of a cell's possible patterns at random, weighted by how
+// often each appears in the samples
+func (w *wfcWave) choose(cell int, rng *rand.Rand) int {
+	patterns := len(w.g.patterns)
+	total := 0
+	for p, weight := range w.g.weights {
+		if w.possible[cell*patterns+p] {
+			total += weight
+		}
+	}
+	pick := rng.Intn(total)
+	for p, weight := range w.g.weights {
+		if w.possible[cell*patterns+p] {
+			if pick < weight {
+				return p
+			}
+			pick -= weight
+		}
+	}
+	return -1
+}
+
+// decided returns the only pattern left for a cell
+func (w *wfcWave) decided(cell int) int {
+	patterns := len(w.g.patterns)
+	for p := 0; p < patterns; p++ {
+		if w.possible[cell*patterns+p] {
+			return p
+		}
+	}
+	return -1
+}
+
+// connect walls in the edges of a generated level, then checks that it's playable
+// using the same walkability rules as GameMap. Everything that can't be reached
+// from the largest open area is filled in. Returns nil if too little is left
+func (g *WFCGenerator) connect(grid [][]byte) []string {
+	lines := make([]string, g.height)
+	for y, row := range grid {
+		for x := range row {
+			if x == 0 || y == 0 || x == g.width-1 || y == g.height-1 {
+				row[x] = '#'
+			}
+		}
+		lines[y] = string(row)
+	}
+
+	m := NewGameMap(strings.Join(lines, "\n"), tileSize, 0, 0)
+	open := func(p image.Point) bool {
+		return !m.IsSolid(p.X, p.Y) && !m.IsHole(p.X, p.Y)
+	}
+
+	// Find the largest open area
+	var largest [][]int
+	largestSize := 0
+	reached := make([][]bool, g.height)
+	for y := range reached {
+		reached[y] = make([]bool, g.width)
+	}
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if reached[y][x] || !open(image.Pt(x, y)) {
+				continue
+			}
+			dist := m.distanceField(image.Pt(x, y), open)
+			size := 0
+			for ry, row := range dist {
+				for rx, d := range row {
+					if d >= 0 {
+						reached[ry][rx] = true
+						size++
+					}
+				}
+			}
+			if size > largestSize {
+				largest, largestSize = dist, size
+			}
+		}
+	}
+	if float64(largestSize) < g.MinOpenFraction*float64(g.width*g.height) {
+		return nil
+	}
+
+	// Fill in the rest. Holes bordering the open area are kept as hazards
+	for y, row := range grid {
+		for x := range row {
+			p := image.Pt(x, y)
+			if (largest[y][x] < 0 && asciiWalkable(row[x])) || (m.IsHole(x, y) && !g.touches(largest, p)) {
+				row[x] = '#'
+			}
+		}
+		lines[y] = string(row)
+	}
+	return lines
+}
+
+// touches checks if any of the 4 tiles around the given one have been reached
+func (g *WFCGenerator) touches(dist [][]int, p image.Point) bool {
+	for _, step := range cellSteps {
+		n := p.Add(step)
+		if n.X >= 0 && n.Y >= 0 && n.X < g.width && n.Y < g.height && dist[n.Y][n.X] >= 0 {
+			return true
+		}
+	}
+	return false
+}